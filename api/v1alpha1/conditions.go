@@ -0,0 +1,80 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+// Condition types reported in VllmDeploymentStatus.Conditions.
+const (
+	// ConditionReady is True when every desired replica runs the latest pod
+	// template and is ready to serve requests.
+	ConditionReady = "Ready"
+	// ConditionAvailable mirrors the Available condition of the owned Deployment.
+	// For the other workload kinds it is True when at least one replica is ready.
+	ConditionAvailable = "Available"
+	// ConditionProgressing is True while a rollout of the owned workload is in progress.
+	ConditionProgressing = "Progressing"
+	// ConditionDegraded is True when the rollout is stuck or replicas failed to be created.
+	ConditionDegraded = "Degraded"
+	// ConditionModelLoaded is True when at least one vLLM pod serves the model.
+	ConditionModelLoaded = "ModelLoaded"
+	// ConditionModelDownloaded is True once the model weights are in the model
+	// cache. It is only reported when spec.model.cache is set.
+	ConditionModelDownloaded = "ModelDownloaded"
+	// ConditionCredentialsMissing is True when the Secret referenced by
+	// spec.model.tokenSecretRef or its key does not exist.
+	ConditionCredentialsMissing = "CredentialsMissing"
+	// ConditionScaledToZero is True while the workload is scaled to zero by
+	// spec.idle and the Service routes requests to the activator. It is only
+	// reported when spec.idle is set.
+	ConditionScaledToZero = "ScaledToZero"
+	// ConditionReconcileError is True when the last reconciliation failed.
+	ConditionReconcileError = "ReconcileError"
+	// ConditionPaused is True while spec.paused or the
+	// vllmoperator.org/paused annotation stops the operator from changing
+	// the objects of the VllmDeployment. It is only reported once paused.
+	ConditionPaused = "Paused"
+)
+
+// Condition reasons reported in VllmDeploymentStatus.Conditions.
+const (
+	ReasonAllReplicasReady         = "AllReplicasReady"
+	ReasonReplicasNotReady         = "ReplicasNotReady"
+	ReasonDeploymentAvailable      = "DeploymentAvailable"
+	ReasonDeploymentUnavailable    = "DeploymentUnavailable"
+	ReasonReplicasAvailable        = "ReplicasAvailable"
+	ReasonNoReplicasAvailable      = "NoReplicasAvailable"
+	ReasonRolloutInProgress        = "RolloutInProgress"
+	ReasonRolloutComplete          = "RolloutComplete"
+	ReasonProgressDeadlineExceeded = "ProgressDeadlineExceeded"
+	ReasonReplicaFailure           = "ReplicaFailure"
+	ReasonAsExpected               = "AsExpected"
+	ReasonModelServing             = "ModelServing"
+	ReasonNoReadyReplicas          = "NoReadyReplicas"
+	ReasonDownloading              = "Downloading"
+	ReasonDownloaded               = "Downloaded"
+	ReasonDownloadFailed           = "DownloadFailed"
+	ReasonSecretNotFound           = "SecretNotFound"
+	ReasonSecretKeyNotFound        = "SecretKeyNotFound"
+	ReasonCredentialsFound         = "CredentialsFound"
+	ReasonIdle                     = "Idle"
+	ReasonWakingUp                 = "WakingUp"
+	ReasonServing                  = "Serving"
+	ReasonReconcileSucceeded       = "ReconcileSucceeded"
+	ReasonReconcileFailed          = "ReconcileFailed"
+	ReasonPausedBySpec             = "PausedBySpec"
+	ReasonPausedByAnnotation       = "PausedByAnnotation"
+	ReasonResumed                  = "Resumed"
+)
@@ -0,0 +1,85 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+// Reasons of the events recorded on a VllmDeployment. Normal events report
+// the lifecycle, Warning events the failures to alert on. The reasons are
+// part of the API: they are only ever added, never renamed.
+const (
+	// EventReasonCreated is recorded when the operator creates an object of
+	// the VllmDeployment: its workload, Services, model cache claim, HPA or
+	// activator.
+	EventReasonCreated = "Created"
+	// EventReasonUpdated is recorded when the operator updates an object of
+	// the VllmDeployment, usually after a change of its spec.
+	EventReasonUpdated = "Updated"
+	// EventReasonRolloutComplete is recorded when every replica is ready
+	// with the latest pod template.
+	EventReasonRolloutComplete = "RolloutComplete"
+	// EventReasonRolloutFailed is a warning recorded when the rollout is
+	// stuck or replicas fail to be created.
+	EventReasonRolloutFailed = "RolloutFailed"
+	// EventReasonScaledUp is recorded when the replicas of the workload are
+	// increased, by spec.replicas, spec.idle or the Operator autoscaling
+	// provider.
+	EventReasonScaledUp = "ScaledUp"
+	// EventReasonScaledDown is recorded when the replicas of the workload are
+	// decreased, by spec.replicas, spec.idle or the Operator autoscaling
+	// provider.
+	EventReasonScaledDown = "ScaledDown"
+	// EventReasonInvalidSpec is a warning recorded when the controller
+	// rejects the spec, which the admission webhook normally prevents.
+	EventReasonInvalidSpec = "InvalidSpec"
+	// EventReasonReconcileFailed is a warning recorded when a reconciliation
+	// starts failing, with the error.
+	EventReasonReconcileFailed = "ReconcileFailed"
+	// EventReasonModelDownloadFailed is a warning recorded when the model
+	// downloader init container fails.
+	EventReasonModelDownloadFailed = "ModelDownloadFailed"
+	// EventReasonPodCrashLooping is a warning recorded once per restart of a
+	// container of a vLLM pod in CrashLoopBackOff.
+	EventReasonPodCrashLooping = "PodCrashLooping"
+	// EventReasonMetricsUnavailable is a warning recorded when the Operator
+	// autoscaling provider stops being able to scrape any ready pod.
+	EventReasonMetricsUnavailable = "MetricsUnavailable"
+	// EventReasonScaledToZero is recorded when spec.idle scales the workload
+	// to zero.
+	EventReasonScaledToZero = "ScaledToZero"
+	// EventReasonWakingUp is recorded when a request held by the activator
+	// scales the workload up again.
+	EventReasonWakingUp = "WakingUp"
+	// EventReasonColdStartComplete is recorded when the first replica is
+	// ready after a wake up, with the cold start latency.
+	EventReasonColdStartComplete = "ColdStartComplete"
+	// EventReasonDraining is recorded when the deletion starts waiting for
+	// the vLLM pods to finish their requests and terminate.
+	EventReasonDraining = "Draining"
+	// EventReasonModelCacheDeleted is recorded when the deletion removes the
+	// model cache claim.
+	EventReasonModelCacheDeleted = "ModelCacheDeleted"
+	// EventReasonModelCacheRetained is recorded when the deletion keeps the
+	// model cache claim with deletionPolicy Retain.
+	EventReasonModelCacheRetained = "ModelCacheRetained"
+	// EventReasonCleanupComplete is recorded when the deletion cleaned up
+	// and releases the VllmDeployment.
+	EventReasonCleanupComplete = "CleanupComplete"
+	// EventReasonPaused is recorded when the reconciliation is paused by
+	// spec.paused or the vllmoperator.org/paused annotation.
+	EventReasonPaused = "Paused"
+	// EventReasonResumed is recorded when the reconciliation resumes.
+	EventReasonResumed = "Resumed"
+)
@@ -17,10 +17,37 @@ limitations under the License.
 package v1alpha1
 
 import (
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
+// Defaults applied by the defaulting webhook and assumed by the controller.
+const (
+	// DefaultVllmContainerName is the name of the container running the vLLM server
+	// when the pod has more than one container.
+	DefaultVllmContainerName = "vllm"
+	// ModelDownloaderContainerName is the name of the init container the
+	// operator adds to fetch the weights into the model cache.
+	ModelDownloaderContainerName = "model-downloader"
+	// DefaultVllmImage is the image used for the vLLM container when none is set.
+	DefaultVllmImage = "vllm/vllm-openai:v0.6.2"
+	// DefaultVllmPort is the port the vLLM OpenAI server listens on when none is set.
+	DefaultVllmPort = 8000
+	// ServingPortName is the name of the port of the vLLM container serving
+	// vLLMConfig.port, which the operator sets.
+	ServingPortName = "http"
+	// DefaultReplicas is the number of replicas used when none is set.
+	DefaultReplicas int32 = 1
+	// DefaultGPUResourceName is the extended resource requested for GPUs when none is set.
+	DefaultGPUResourceName = "nvidia.com/gpu"
+	// DefaultGPUProductLabel is the node label matched against the GPU product when none is set.
+	DefaultGPUProductLabel = "nvidia.com/gpu.product"
+	// DefaultRayPort is the port of the Ray head of a multi-node group when none is set.
+	DefaultRayPort = 6379
+)
+
 // VllmDeploymentSpec defines the desired state of VllmDeployment.
 type VllmDeploymentSpec struct {
 	Replicas       *int32          `json:"replicas"`
@@ -29,12 +56,208 @@ type VllmDeploymentSpec struct {
 	Tolerations    []v1.Toleration `json:"tolerations,omitempty"`
 	Containers     []v1.Container  `json:"containers,omitempty"`
 	InitContainers []v1.Container  `json:"initContainers,omitempty"`
+	// Volumes that can be mounted by the containers of the vLLM pods.
+	// +optional
+	Volumes []v1.Volume `json:"volumes,omitempty"`
+	// NodeSelector constrains the vLLM pods to nodes with matching labels.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+	// Affinity holds the scheduling constraints of the vLLM pods.
+	// +optional
+	Affinity *v1.Affinity `json:"affinity,omitempty"`
+	// ImagePullSecrets used to pull the container images.
+	// +optional
+	ImagePullSecrets []v1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+	// ServiceAccountName is the name of the ServiceAccount the vLLM pods run as.
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+	// PriorityClassName is the priority class of the vLLM pods.
+	// +optional
+	PriorityClassName string `json:"priorityClassName,omitempty"`
+	// Service configures the Service that exposes the vLLM port.
+	// +optional
+	Service *ServiceConfig `json:"service,omitempty"`
+	// GPU requests accelerators for each vLLM pod and derives the resource
+	// limits, tensor parallelism, shared memory and node affinity from them.
+	// +optional
+	GPU *GPUConfig `json:"gpu,omitempty"`
+	// MultiNode spreads each replica over a group of pods joined in a Ray
+	// cluster, for models that do not fit on a single node.
+	// +optional
+	MultiNode *MultiNodeConfig `json:"multiNode,omitempty"`
+	// WorkloadKind is the kind of object running the vLLM pods. Defaults to
+	// Deployment, or with multiNode to LeaderWorkerSet when its CRD is
+	// installed and StatefulSet otherwise. Changing it deletes the object of
+	// the previous kind.
+	// +optional
+	WorkloadKind WorkloadKind `json:"workloadKind,omitempty"`
+	// Probes overrides the startup, readiness and liveness probes the operator
+	// adds to the vllm container on the /health endpoint.
+	// +optional
+	Probes *ProbesConfig `json:"probes,omitempty"`
+	// Autoscaling scales the replicas between minReplicas and maxReplicas on
+	// the vLLM queue, KV-cache and throughput metrics. Replicas is only used
+	// when it is not set.
+	// +optional
+	Autoscaling *AutoscalingConfig `json:"autoscaling,omitempty"`
+	// Idle scales the workload to zero when the model receives no request
+	// for a while. An activator then receives the requests sent to the
+	// Service and holds them until the model is ready again.
+	// +optional
+	Idle *IdleConfig `json:"idle,omitempty"`
+	// DeletionPolicy is what happens to the model cache when the
+	// VllmDeployment is deleted: Delete (the default) deletes the claim
+	// provisioned by the operator, and an existingClaim annotated with
+	// vllmoperator.org/ephemeral=true; Retain keeps the claim, so that a new
+	// VllmDeployment can reuse the weights through existingClaim.
+	// +kubebuilder:default=Delete
+	// +optional
+	DeletionPolicy DeletionPolicy `json:"deletionPolicy,omitempty"`
+	// IgnoreConfigChanges lists the ConfigMaps and Secrets read by the vLLM
+	// pods whose changes do not roll them out. The content of every other
+	// ConfigMap and Secret referenced by the volumes, the environment or the
+	// model source is hashed into the vllmoperator.org/config-hash annotation
+	// of the pod template, so that the pods are replaced when it changes.
+	// +optional
+	IgnoreConfigChanges []ConfigReference `json:"ignoreConfigChanges,omitempty"`
+	// Paused stops the operator from changing the objects of the
+	// VllmDeployment, e.g. to hand-edit the workload during an incident,
+	// while it keeps reporting the status. The vllmoperator.org/paused: "true"
+	// annotation pauses it as well.
+	// +optional
+	Paused bool `json:"paused,omitempty"`
 	// TODO (similar to prometheus): VolumeClaimTemplate EmbeddedPersistentVolumeClaim `json:"volumeClaimTemplate,omitempty"`
 }
 
 type ModelConfig struct {
 	Name  string `json:"name"`
 	HfURL string `json:"hf_url"`
+	// Source is where the weights are loaded from. Defaults to the Hugging
+	// Face repository given by name.
+	// +optional
+	Source *ModelSource `json:"source,omitempty"`
+	// Cache stores the model weights on a PersistentVolumeClaim that is filled
+	// once by a download init container instead of on every pod start.
+	// +optional
+	Cache *ModelCacheConfig `json:"cache,omitempty"`
+	// TokenSecretRef references the Secret key holding the Hugging Face token
+	// used to download gated models. It is exposed to vLLM and the model
+	// downloader as HF_TOKEN.
+	// +optional
+	TokenSecretRef *SecretKeyRef `json:"tokenSecretRef,omitempty"`
+}
+
+// SecretKeyRef references a key of a Secret in the namespace of the VllmDeployment.
+type SecretKeyRef struct {
+	// Name of the Secret.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+	// Key of the Secret holding the value. Defaults to "token".
+	// +kubebuilder:default=token
+	// +optional
+	Key string `json:"key,omitempty"`
+}
+
+// ModelSourceType is the kind of storage the model weights are loaded from.
+// +kubebuilder:validation:Enum=huggingface;s3;oci;pvc;hostPath
+type ModelSourceType string
+
+const (
+	ModelSourceHuggingFace ModelSourceType = "huggingface"
+	ModelSourceS3          ModelSourceType = "s3"
+	ModelSourceOCI         ModelSourceType = "oci"
+	ModelSourcePVC         ModelSourceType = "pvc"
+	ModelSourceHostPath    ModelSourceType = "hostPath"
+)
+
+// ModelSource describes where the model weights are loaded from. Only the
+// field matching type may be set.
+type ModelSource struct {
+	// Type of the source.
+	// +kubebuilder:default=huggingface
+	Type ModelSourceType `json:"type"`
+	// S3 fetches the weights from an S3-compatible bucket, e.g. AWS S3, MinIO
+	// or Google Cloud Storage through its S3 interoperability endpoint.
+	// +optional
+	S3 *S3ModelSource `json:"s3,omitempty"`
+	// OCI pulls the weights from an artifact stored in an OCI registry.
+	// +optional
+	OCI *OCIModelSource `json:"oci,omitempty"`
+	// PVC loads the weights from an existing PersistentVolumeClaim.
+	// +optional
+	PVC *PVCModelSource `json:"pvc,omitempty"`
+	// HostPath loads the weights from a directory on the node.
+	// +optional
+	HostPath *HostPathModelSource `json:"hostPath,omitempty"`
+}
+
+type S3ModelSource struct {
+	// URI of the prefix holding the weights, e.g. s3://models/llama-3.1-8b.
+	// +kubebuilder:validation:Pattern=`^s3://`
+	URI string `json:"uri"`
+	// Endpoint of an S3-compatible store, e.g. http://minio.minio:9000 or
+	// https://storage.googleapis.com. Defaults to AWS.
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+	// Region of the bucket.
+	// +optional
+	Region string `json:"region,omitempty"`
+	// CredentialsSecretRef is the name of a Secret with the AWS_ACCESS_KEY_ID
+	// and AWS_SECRET_ACCESS_KEY keys.
+	// +optional
+	CredentialsSecretRef *v1.LocalObjectReference `json:"credentialsSecretRef,omitempty"`
+	// FetcherImage is the image with the aws CLI used to fetch the weights.
+	// +optional
+	FetcherImage string `json:"fetcherImage,omitempty"`
+}
+
+type OCIModelSource struct {
+	// Reference of the artifact, e.g. registry.example.com/models/llama:3.1-8b.
+	// +kubebuilder:validation:MinLength=1
+	Reference string `json:"reference"`
+	// PullSecretRef is the name of a kubernetes.io/dockerconfigjson Secret
+	// used to authenticate to the registry.
+	// +optional
+	PullSecretRef *v1.LocalObjectReference `json:"pullSecretRef,omitempty"`
+	// FetcherImage is the image with the oras CLI used to pull the artifact.
+	// +optional
+	FetcherImage string `json:"fetcherImage,omitempty"`
+}
+
+type PVCModelSource struct {
+	// ClaimName of the PersistentVolumeClaim holding the weights.
+	// +kubebuilder:validation:MinLength=1
+	ClaimName string `json:"claimName"`
+	// Path of the weights inside the volume. Defaults to its root.
+	// +optional
+	Path string `json:"path,omitempty"`
+}
+
+type HostPathModelSource struct {
+	// Path of the directory holding the weights on the node.
+	// +kubebuilder:validation:MinLength=1
+	Path string `json:"path"`
+}
+
+type ModelCacheConfig struct {
+	// ExistingClaim is the name of a PersistentVolumeClaim to store the weights in.
+	// When empty the operator provisions a claim named <name>-model-cache.
+	// +optional
+	ExistingClaim string `json:"existingClaim,omitempty"`
+	// Size of the provisioned claim. Required when existingClaim is empty.
+	// +optional
+	Size *resource.Quantity `json:"size,omitempty"`
+	// StorageClassName of the provisioned claim. The cluster default is used when empty.
+	// +optional
+	StorageClassName *string `json:"storageClassName,omitempty"`
+	// AccessModes of the provisioned claim. Defaults to ReadWriteOnce; use
+	// ReadWriteMany when replicas are spread across nodes.
+	// +optional
+	AccessModes []v1.PersistentVolumeAccessMode `json:"accessModes,omitempty"`
+	// DownloaderImage is the image of the init container downloading the
+	// weights. It needs huggingface-cli and defaults to the vllm container image.
+	// +optional
+	DownloaderImage string `json:"downloaderImage,omitempty"`
 }
 
 type VLLMConfig struct {
@@ -44,28 +267,437 @@ type VLLMConfig struct {
 	BlockSize            int    `json:"block-size"`
 	MaxModelLen          int    `json:"max-model-len"`
 	EnforceEager         bool   `json:"enforce-eager"`
+
+	// TensorParallelSize is the number of GPUs each model layer is split across.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	TensorParallelSize int `json:"tensor-parallel-size,omitempty"`
+	// PipelineParallelSize is the number of pipeline stages the model layers are split into.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	PipelineParallelSize int `json:"pipeline-parallel-size,omitempty"`
+	// Dtype of the model weights and activations.
+	// +kubebuilder:validation:Enum=auto;half;float16;bfloat16;float;float32
+	// +optional
+	Dtype string `json:"dtype,omitempty"`
+	// Quantization method of the weights, e.g. awq, gptq, fp8 or bitsandbytes.
+	// +kubebuilder:validation:Pattern=`^[a-z0-9_-]+$`
+	// +optional
+	Quantization string `json:"quantization,omitempty"`
+	// KVCacheDtype is the data type of the KV cache.
+	// +kubebuilder:validation:Enum=auto;fp8;fp8_e4m3;fp8_e5m2
+	// +optional
+	KVCacheDtype string `json:"kv-cache-dtype,omitempty"`
+	// MaxNumSeqs is the maximum number of sequences per iteration.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxNumSeqs int `json:"max-num-seqs,omitempty"`
+	// MaxNumBatchedTokens is the maximum number of batched tokens per iteration.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MaxNumBatchedTokens int `json:"max-num-batched-tokens,omitempty"`
+	// EnablePrefixCaching reuses the KV cache of shared prompt prefixes.
+	// +optional
+	EnablePrefixCaching bool `json:"enable-prefix-caching,omitempty"`
+	// EnableChunkedPrefill splits long prefills into chunks batched with decodes.
+	// +optional
+	EnableChunkedPrefill bool `json:"enable-chunked-prefill,omitempty"`
+	// SwapSpace is the CPU swap space per GPU in GiB, e.g. "4".
+	// +optional
+	SwapSpace string `json:"swap-space,omitempty"`
+	// ServedModelName are the names the model is served under in the API.
+	// Defaults to model.name.
+	// +optional
+	ServedModelName []string `json:"served-model-name,omitempty"`
+	// TrustRemoteCode allows running code shipped with the model from Hugging Face.
+	// +optional
+	TrustRemoteCode bool `json:"trust-remote-code,omitempty"`
+	// Seed of the random number generator.
+	// +optional
+	Seed *int `json:"seed,omitempty"`
+	// Revision of the model on Hugging Face: a branch name, tag or commit id.
+	// +optional
+	Revision string `json:"revision,omitempty"`
+
+	// ExtraArgs are passed to vLLM as is, in order, after the flags above,
+	// e.g. ["--disable-log-requests", "--max-log-len", "100"]. Flags set by the
+	// operator or through the fields above are rejected.
+	// +optional
+	ExtraArgs []string `json:"extraArgs,omitempty"`
+	// ExtraArgsMap maps flag names, with or without the leading "--", to their
+	// value and is passed after extraArgs in flag name order. An empty value passes
+	// the flag alone.
+	// +optional
+	ExtraArgsMap map[string]string `json:"extraArgsMap,omitempty"`
+}
+
+// WorkloadKind is the kind of object running the vLLM pods.
+// +kubebuilder:validation:Enum=Deployment;StatefulSet;LeaderWorkerSet
+type WorkloadKind string
+
+const (
+	WorkloadKindDeployment  WorkloadKind = "Deployment"
+	WorkloadKindStatefulSet WorkloadKind = "StatefulSet"
+	// WorkloadKindLeaderWorkerSet needs the LeaderWorkerSet CRD from
+	// sigs.k8s.io/lws to be installed.
+	WorkloadKindLeaderWorkerSet WorkloadKind = "LeaderWorkerSet"
+)
+
+// DeletionPolicy is what happens to the model cache on deletion.
+// +kubebuilder:validation:Enum=Delete;Retain
+type DeletionPolicy string
+
+const (
+	DeletionPolicyDelete DeletionPolicy = "Delete"
+	DeletionPolicyRetain DeletionPolicy = "Retain"
+)
+
+// ConfigReferenceKind is the kind of object holding the configuration of the
+// vLLM pods.
+// +kubebuilder:validation:Enum=ConfigMap;Secret
+type ConfigReferenceKind string
+
+const (
+	ConfigReferenceConfigMap ConfigReferenceKind = "ConfigMap"
+	ConfigReferenceSecret    ConfigReferenceKind = "Secret"
+)
+
+// ConfigReference references a ConfigMap or a Secret in the namespace of the
+// VllmDeployment.
+type ConfigReference struct {
+	// Kind of the object.
+	Kind ConfigReferenceKind `json:"kind"`
+	// Name of the object.
+	// +kubebuilder:validation:MinLength=1
+	Name string `json:"name"`
+}
+
+// ServiceType is the kind of Service created in front of the vLLM pods.
+// +kubebuilder:validation:Enum=ClusterIP;NodePort;LoadBalancer;Headless
+type ServiceType string
+
+const (
+	ServiceTypeClusterIP    ServiceType = "ClusterIP"
+	ServiceTypeNodePort     ServiceType = "NodePort"
+	ServiceTypeLoadBalancer ServiceType = "LoadBalancer"
+	// ServiceTypeHeadless creates a ClusterIP Service with clusterIP set to None.
+	ServiceTypeHeadless ServiceType = "Headless"
+)
+
+type ServiceConfig struct {
+	// Type of the Service. Defaults to ClusterIP.
+	// +kubebuilder:default=ClusterIP
+	// +optional
+	Type ServiceType `json:"type,omitempty"`
+	// Port exposed by the Service. Defaults to vLLMConfig.port.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	Port int32 `json:"port,omitempty"`
+	// NodePort to use when type is NodePort or LoadBalancer. Allocated by the cluster when unset.
+	// +optional
+	NodePort int32 `json:"nodePort,omitempty"`
+	// Annotations added to the Service, e.g. for cloud load balancer settings.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type GPUConfig struct {
+	// Count of GPUs per pod. vLLM shards the model across all of them unless
+	// vLLMConfig.tensor-parallel-size is set, which must divide the count.
+	// +kubebuilder:validation:Minimum=1
+	Count int32 `json:"count"`
+	// ResourceName is the extended resource of the GPUs, e.g. nvidia.com/gpu or amd.com/gpu.
+	// +kubebuilder:default="nvidia.com/gpu"
+	// +optional
+	ResourceName v1.ResourceName `json:"resourceName,omitempty"`
+	// Product restricts the pods to nodes whose productLabel has this value,
+	// e.g. NVIDIA-A100-SXM4-80GB.
+	// +optional
+	Product string `json:"product,omitempty"`
+	// ProductLabel is the node label holding the GPU product.
+	// +kubebuilder:default="nvidia.com/gpu.product"
+	// +optional
+	ProductLabel string `json:"productLabel,omitempty"`
+	// SharedMemorySize limits the memory-backed volume mounted at /dev/shm,
+	// used by NCCL to exchange data between the GPUs. Unlimited when empty.
+	// +optional
+	SharedMemorySize *resource.Quantity `json:"sharedMemorySize,omitempty"`
+}
+
+// MultiNodeConfig describes the groups of pods serving one replica. The
+// leader of each group runs the Ray head and the vLLM server, the workers join
+// its Ray cluster and the model is split across them with pipeline parallelism.
+// A LeaderWorkerSet is created when its CRD is installed, otherwise a leader
+// and a worker StatefulSet.
+type MultiNodeConfig struct {
+	// Size is the number of pods of each group, leader included.
+	// vLLMConfig.pipeline-parallel-size defaults to it.
+	// +kubebuilder:validation:Minimum=2
+	Size int32 `json:"size"`
+	// RayPort is the port of the Ray head on the leader. Defaults to 6379.
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +optional
+	RayPort int32 `json:"rayPort,omitempty"`
+}
+
+// ProbesConfig overrides the default probes of the vllm container. The fields
+// set on a probe override the ones of the default probe, so a probe without a
+// handler keeps the default httpGet on /health and only its timings need to be
+// set.
+type ProbesConfig struct {
+	// Startup probe, by default allowing 30 minutes for the model to load.
+	// +optional
+	Startup *ProbeOverride `json:"startup,omitempty"`
+	// Readiness probe, routing traffic to the pod only once the engine serves requests.
+	// +optional
+	Readiness *ProbeOverride `json:"readiness,omitempty"`
+	// Liveness probe, restarting the container when the engine stops responding.
+	// +optional
+	Liveness *ProbeOverride `json:"liveness,omitempty"`
+}
+
+// ProbeOverride overrides a default probe of the vllm container, or removes it.
+type ProbeOverride struct {
+	// Disabled removes the probe from the vllm container, e.g. the liveness
+	// probe of an engine too slow to answer under load.
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
+	v1.Probe `json:",inline"`
+}
+
+// AutoscalingConfig describes how the replicas of the workload are scaled on
+// the vLLM metrics. At least one target must be set; the autoscaler scales on
+// the one asking for the most replicas.
+type AutoscalingConfig struct {
+	// Provider is the autoscaler in charge of the replicas: HPA (the
+	// default) creates a HorizontalPodAutoscaler reading the metrics through
+	// the custom or external metrics API, e.g. served by prometheus-adapter.
+	// Operator scrapes the /metrics endpoint of every vLLM pod from the
+	// operator and writes the replicas to the scale subresource of the
+	// workload, for clusters without a metrics adapter.
+	// +kubebuilder:default=HPA
+	// +optional
+	Provider AutoscalingProvider `json:"provider,omitempty"`
+	// MinReplicas is the lower limit of the replicas. Defaults to 1.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	MinReplicas *int32 `json:"minReplicas,omitempty"`
+	// MaxReplicas is the upper limit of the replicas.
+	// +kubebuilder:validation:Minimum=1
+	MaxReplicas int32 `json:"maxReplicas"`
+	// MetricType is the metrics API the targets are read from: Pods (the
+	// custom metrics of each pod, the default) or External (metrics selected
+	// with the app=<name> label). Only used by the HPA provider.
+	// +kubebuilder:default=Pods
+	// +optional
+	MetricType AutoscalingMetricType `json:"metricType,omitempty"`
+	// NumRequestsWaiting targets the requests waiting in the queue of each
+	// replica, vllm:num_requests_waiting.
+	// +optional
+	NumRequestsWaiting *MetricTarget `json:"numRequestsWaiting,omitempty"`
+	// NumRequestsRunning targets the requests being processed by each
+	// replica, vllm:num_requests_running.
+	// +optional
+	NumRequestsRunning *MetricTarget `json:"numRequestsRunning,omitempty"`
+	// GPUCacheUsage targets the fraction of the KV cache used on each
+	// replica, vllm:gpu_cache_usage_perc, between 0 and 1.
+	// +optional
+	GPUCacheUsage *MetricTarget `json:"gpuCacheUsage,omitempty"`
+	// TokensPerSecond targets the generated tokens per second of each
+	// replica. vLLM only exposes the vllm:generation_tokens_total counter, so
+	// the metrics adapter must serve its rate, by default as
+	// vllm:generation_tokens_per_second. Only supported by the HPA provider.
+	// +optional
+	TokensPerSecond *MetricTarget `json:"tokensPerSecond,omitempty"`
+	// Behavior configures the scaling velocity and stabilization windows of
+	// the HPA.
+	// +optional
+	Behavior *autoscalingv2.HorizontalPodAutoscalerBehavior `json:"behavior,omitempty"`
+	// Operator configures the Operator provider.
+	// +optional
+	Operator *OperatorAutoscalerConfig `json:"operator,omitempty"`
+}
+
+// AutoscalingProvider is the autoscaler in charge of the replicas.
+// +kubebuilder:validation:Enum=HPA;Operator
+type AutoscalingProvider string
+
+const (
+	AutoscalingProviderHPA      AutoscalingProvider = "HPA"
+	AutoscalingProviderOperator AutoscalingProvider = "Operator"
+)
+
+// OperatorAutoscalerConfig tunes the autoscaler built into the operator. Like
+// the HPA, it scales up to the lowest and down to the highest replica count
+// recommended within the stabilization windows, and in addition waits for the
+// cooldown after each scaling before scaling in the same direction again.
+type OperatorAutoscalerConfig struct {
+	// SyncPeriod is how often the metrics are scraped. Defaults to 15s.
+	// +optional
+	SyncPeriod *metav1.Duration `json:"syncPeriod,omitempty"`
+	// ScaleUpStabilizationWindow is how long a higher recommendation must
+	// hold before scaling up. Defaults to 0.
+	// +optional
+	ScaleUpStabilizationWindow *metav1.Duration `json:"scaleUpStabilizationWindow,omitempty"`
+	// ScaleDownStabilizationWindow is how long a lower recommendation must
+	// hold before scaling down. Defaults to 5m.
+	// +optional
+	ScaleDownStabilizationWindow *metav1.Duration `json:"scaleDownStabilizationWindow,omitempty"`
+	// ScaleUpCooldown is the minimum time between a scaling and the next
+	// scale up. Defaults to 1m.
+	// +optional
+	ScaleUpCooldown *metav1.Duration `json:"scaleUpCooldown,omitempty"`
+	// ScaleDownCooldown is the minimum time between a scaling and the next
+	// scale down. Defaults to 5m.
+	// +optional
+	ScaleDownCooldown *metav1.Duration `json:"scaleDownCooldown,omitempty"`
+}
+
+// AutoscalingMetricType is the metrics API the autoscaling targets are read from.
+// +kubebuilder:validation:Enum=Pods;External
+type AutoscalingMetricType string
+
+const (
+	AutoscalingMetricTypePods     AutoscalingMetricType = "Pods"
+	AutoscalingMetricTypeExternal AutoscalingMetricType = "External"
+)
+
+// MetricTarget is the average value of a metric per replica the autoscaler
+// keeps the workload at.
+type MetricTarget struct {
+	// AverageValue is the target value of the metric averaged over the replicas.
+	AverageValue resource.Quantity `json:"averageValue"`
+	// MetricName overrides the name of the metric in the metrics API, when
+	// the adapter renames the vLLM metrics.
+	// +optional
+	MetricName string `json:"metricName,omitempty"`
+}
+
+// IdleConfig describes when the workload is scaled to zero and how requests
+// wake it up.
+type IdleConfig struct {
+	// After is how long the model must go without requests, as counted by
+	// vllm:request_success_total and the requests in flight, before the
+	// workload is scaled to zero, e.g. 30m.
+	After metav1.Duration `json:"after"`
+	// ActivationTimeout is how long the activator holds a request while the
+	// model starts before failing it. Defaults to 15m.
+	// +optional
+	ActivationTimeout *metav1.Duration `json:"activationTimeout,omitempty"`
+	// ActivatorImage overrides the image of the activator, by default the
+	// one set with the --activator-image flag of the operator.
+	// +optional
+	ActivatorImage string `json:"activatorImage,omitempty"`
 }
 
 // VllmDeploymentStatus defines the observed state of VllmDeployment.
 type VllmDeploymentStatus struct {
-	// The current state of the Prometheus deployment.
+	// ObservedGeneration is the most recent generation observed by the controller.
+	// +optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Phase summarizes the conditions for the printer columns.
+	// +optional
+	Phase VllmDeploymentPhase `json:"phase,omitempty"`
+	// WorkloadKind is the kind of the object running the vLLM pods.
+	// +optional
+	WorkloadKind WorkloadKind `json:"workloadKind,omitempty"`
+	// Selector is the label selector of the pods serving the API, in the
+	// string form used by the scale subresource.
+	// +optional
+	Selector string `json:"selector,omitempty"`
+	// Replicas is the number of replicas targeted by the owned workload. With
+	// spec.multiNode a replica is a group of pods.
+	// +optional
+	Replicas int32 `json:"replicas,omitempty"`
+	// ReadyReplicas is the number of replicas that are ready to serve requests.
+	// +optional
+	ReadyReplicas int32 `json:"readyReplicas,omitempty"`
+	// Restarts is the number of restarts of the containers of the vLLM pods.
+	// +optional
+	Restarts int32 `json:"restarts,omitempty"`
+	// UpdatedReplicas is the number of replicas running the latest pod template.
+	// +optional
+	UpdatedReplicas int32 `json:"updatedReplicas,omitempty"`
+	// Endpoint is the in-cluster URL of the OpenAI compatible server.
+	// +optional
+	Endpoint string `json:"endpoint,omitempty"`
+	// Image is the vLLM container image currently deployed.
+	// +optional
+	Image string `json:"image,omitempty"`
+	// Model is the model currently served.
+	// +optional
+	Model string `json:"model,omitempty"`
+	// Autoscaler reports the last decision of the Operator autoscaling provider.
+	// +optional
+	Autoscaler *AutoscalerStatus `json:"autoscaler,omitempty"`
+	// Idle reports the scale to zero state of a vllmDeployment with spec.idle.
+	// +optional
+	Idle *IdleStatus `json:"idle,omitempty"`
+	// Conditions represent the latest available observations of the VllmDeployment's state.
 	// +listType=map
 	// +listMapKey=type
 	// +optional
-	Conditions []Condition `json:"conditions,omitempty"`
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
 }
 
-type Condition struct {
-	// Type of the condition being reported.
-	// +required
-	Type ConditionType `json:"type"`
+// AutoscalerStatus is the state of the autoscaler built into the operator.
+type AutoscalerStatus struct {
+	// DesiredReplicas is the replica count last computed from the metrics.
+	// +optional
+	DesiredReplicas int32 `json:"desiredReplicas,omitempty"`
+	// LastScaleTime is when the autoscaler last changed the replicas.
+	// +optional
+	LastScaleTime *metav1.Time `json:"lastScaleTime,omitempty"`
 }
 
-// +kubebuilder:validation:MinLength=1
-type ConditionType string
+// VllmDeploymentPhase is a one word summary of the state of a VllmDeployment.
+type VllmDeploymentPhase string
+
+const (
+	// PhasePending is reported until the workload starts rolling out.
+	PhasePending VllmDeploymentPhase = "Pending"
+	// PhaseProgressing is reported while the model is downloaded or the
+	// replicas roll out.
+	PhaseProgressing VllmDeploymentPhase = "Progressing"
+	// PhaseReady is reported when every replica serves the model.
+	PhaseReady VllmDeploymentPhase = "Ready"
+	// PhaseDegraded is reported when the rollout is stuck or replicas fail.
+	PhaseDegraded VllmDeploymentPhase = "Degraded"
+	// PhaseScaledToZero is reported while spec.idle keeps the workload at
+	// zero replicas or wakes it up.
+	PhaseScaledToZero VllmDeploymentPhase = "ScaledToZero"
+	// PhaseError is reported when the last reconciliation failed.
+	PhaseError VllmDeploymentPhase = "Error"
+	// PhasePaused is reported while the reconciliation is paused.
+	PhasePaused VllmDeploymentPhase = "Paused"
+)
+
+// IdleStatus is the scale to zero state of a vllmDeployment.
+type IdleStatus struct {
+	// ScaledToZeroTime is when the workload was scaled to zero. It is
+	// cleared once a replica is ready again.
+	// +optional
+	ScaledToZeroTime *metav1.Time `json:"scaledToZeroTime,omitempty"`
+	// WakeTime is when a request held by the activator triggered the scale up.
+	// +optional
+	WakeTime *metav1.Time `json:"wakeTime,omitempty"`
+	// LastColdStartDuration is the time the last wake up took, from the
+	// first held request to the first ready replica.
+	// +optional
+	LastColdStartDuration *metav1.Duration `json:"lastColdStartDuration,omitempty"`
+}
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:subresource:scale:specpath=.spec.replicas,statuspath=.status.replicas,selectorpath=.status.selector
+// +kubebuilder:resource:shortName=vd
+// +kubebuilder:printcolumn:name="Model",type=string,JSONPath=`.spec.model.name`
+// +kubebuilder:printcolumn:name="Ready",type=integer,JSONPath=`.status.readyReplicas`
+// +kubebuilder:printcolumn:name="Port",type=integer,JSONPath=`.spec.vLLMConfig.port`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // VllmDeployment is the Schema for the vllmdeployments API.
 type VllmDeployment struct {
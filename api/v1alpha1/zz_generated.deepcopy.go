@@ -21,21 +21,222 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"k8s.io/api/autoscaling/v2"
 	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Condition) DeepCopyInto(out *Condition) {
+func (in *AutoscalerStatus) DeepCopyInto(out *AutoscalerStatus) {
 	*out = *in
+	if in.LastScaleTime != nil {
+		in, out := &in.LastScaleTime, &out.LastScaleTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoscalerStatus.
+func (in *AutoscalerStatus) DeepCopy() *AutoscalerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AutoscalerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AutoscalingConfig) DeepCopyInto(out *AutoscalingConfig) {
+	*out = *in
+	if in.MinReplicas != nil {
+		in, out := &in.MinReplicas, &out.MinReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.NumRequestsWaiting != nil {
+		in, out := &in.NumRequestsWaiting, &out.NumRequestsWaiting
+		*out = new(MetricTarget)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NumRequestsRunning != nil {
+		in, out := &in.NumRequestsRunning, &out.NumRequestsRunning
+		*out = new(MetricTarget)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GPUCacheUsage != nil {
+		in, out := &in.GPUCacheUsage, &out.GPUCacheUsage
+		*out = new(MetricTarget)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TokensPerSecond != nil {
+		in, out := &in.TokensPerSecond, &out.TokensPerSecond
+		*out = new(MetricTarget)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Behavior != nil {
+		in, out := &in.Behavior, &out.Behavior
+		*out = new(v2.HorizontalPodAutoscalerBehavior)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Operator != nil {
+		in, out := &in.Operator, &out.Operator
+		*out = new(OperatorAutoscalerConfig)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoscalingConfig.
+func (in *AutoscalingConfig) DeepCopy() *AutoscalingConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(AutoscalingConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigReference) DeepCopyInto(out *ConfigReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigReference.
+func (in *ConfigReference) DeepCopy() *ConfigReference {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GPUConfig) DeepCopyInto(out *GPUConfig) {
+	*out = *in
+	if in.SharedMemorySize != nil {
+		in, out := &in.SharedMemorySize, &out.SharedMemorySize
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GPUConfig.
+func (in *GPUConfig) DeepCopy() *GPUConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(GPUConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostPathModelSource) DeepCopyInto(out *HostPathModelSource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostPathModelSource.
+func (in *HostPathModelSource) DeepCopy() *HostPathModelSource {
+	if in == nil {
+		return nil
+	}
+	out := new(HostPathModelSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IdleConfig) DeepCopyInto(out *IdleConfig) {
+	*out = *in
+	out.After = in.After
+	if in.ActivationTimeout != nil {
+		in, out := &in.ActivationTimeout, &out.ActivationTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IdleConfig.
+func (in *IdleConfig) DeepCopy() *IdleConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(IdleConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IdleStatus) DeepCopyInto(out *IdleStatus) {
+	*out = *in
+	if in.ScaledToZeroTime != nil {
+		in, out := &in.ScaledToZeroTime, &out.ScaledToZeroTime
+		*out = (*in).DeepCopy()
+	}
+	if in.WakeTime != nil {
+		in, out := &in.WakeTime, &out.WakeTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastColdStartDuration != nil {
+		in, out := &in.LastColdStartDuration, &out.LastColdStartDuration
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IdleStatus.
+func (in *IdleStatus) DeepCopy() *IdleStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(IdleStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetricTarget) DeepCopyInto(out *MetricTarget) {
+	*out = *in
+	out.AverageValue = in.AverageValue.DeepCopy()
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Condition.
-func (in *Condition) DeepCopy() *Condition {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetricTarget.
+func (in *MetricTarget) DeepCopy() *MetricTarget {
 	if in == nil {
 		return nil
 	}
-	out := new(Condition)
+	out := new(MetricTarget)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ModelCacheConfig) DeepCopyInto(out *ModelCacheConfig) {
+	*out = *in
+	if in.Size != nil {
+		in, out := &in.Size, &out.Size
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	if in.StorageClassName != nil {
+		in, out := &in.StorageClassName, &out.StorageClassName
+		*out = new(string)
+		**out = **in
+	}
+	if in.AccessModes != nil {
+		in, out := &in.AccessModes, &out.AccessModes
+		*out = make([]v1.PersistentVolumeAccessMode, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ModelCacheConfig.
+func (in *ModelCacheConfig) DeepCopy() *ModelCacheConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ModelCacheConfig)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -43,6 +244,21 @@ func (in *Condition) DeepCopy() *Condition {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ModelConfig) DeepCopyInto(out *ModelConfig) {
 	*out = *in
+	if in.Source != nil {
+		in, out := &in.Source, &out.Source
+		*out = new(ModelSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Cache != nil {
+		in, out := &in.Cache, &out.Cache
+		*out = new(ModelCacheConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TokenSecretRef != nil {
+		in, out := &in.TokenSecretRef, &out.TokenSecretRef
+		*out = new(SecretKeyRef)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ModelConfig.
@@ -55,9 +271,259 @@ func (in *ModelConfig) DeepCopy() *ModelConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ModelSource) DeepCopyInto(out *ModelSource) {
+	*out = *in
+	if in.S3 != nil {
+		in, out := &in.S3, &out.S3
+		*out = new(S3ModelSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OCI != nil {
+		in, out := &in.OCI, &out.OCI
+		*out = new(OCIModelSource)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PVC != nil {
+		in, out := &in.PVC, &out.PVC
+		*out = new(PVCModelSource)
+		**out = **in
+	}
+	if in.HostPath != nil {
+		in, out := &in.HostPath, &out.HostPath
+		*out = new(HostPathModelSource)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ModelSource.
+func (in *ModelSource) DeepCopy() *ModelSource {
+	if in == nil {
+		return nil
+	}
+	out := new(ModelSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MultiNodeConfig) DeepCopyInto(out *MultiNodeConfig) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MultiNodeConfig.
+func (in *MultiNodeConfig) DeepCopy() *MultiNodeConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(MultiNodeConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OCIModelSource) DeepCopyInto(out *OCIModelSource) {
+	*out = *in
+	if in.PullSecretRef != nil {
+		in, out := &in.PullSecretRef, &out.PullSecretRef
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OCIModelSource.
+func (in *OCIModelSource) DeepCopy() *OCIModelSource {
+	if in == nil {
+		return nil
+	}
+	out := new(OCIModelSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OperatorAutoscalerConfig) DeepCopyInto(out *OperatorAutoscalerConfig) {
+	*out = *in
+	if in.SyncPeriod != nil {
+		in, out := &in.SyncPeriod, &out.SyncPeriod
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.ScaleUpStabilizationWindow != nil {
+		in, out := &in.ScaleUpStabilizationWindow, &out.ScaleUpStabilizationWindow
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.ScaleDownStabilizationWindow != nil {
+		in, out := &in.ScaleDownStabilizationWindow, &out.ScaleDownStabilizationWindow
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.ScaleUpCooldown != nil {
+		in, out := &in.ScaleUpCooldown, &out.ScaleUpCooldown
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.ScaleDownCooldown != nil {
+		in, out := &in.ScaleDownCooldown, &out.ScaleDownCooldown
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OperatorAutoscalerConfig.
+func (in *OperatorAutoscalerConfig) DeepCopy() *OperatorAutoscalerConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(OperatorAutoscalerConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PVCModelSource) DeepCopyInto(out *PVCModelSource) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PVCModelSource.
+func (in *PVCModelSource) DeepCopy() *PVCModelSource {
+	if in == nil {
+		return nil
+	}
+	out := new(PVCModelSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProbeOverride) DeepCopyInto(out *ProbeOverride) {
+	*out = *in
+	in.Probe.DeepCopyInto(&out.Probe)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbeOverride.
+func (in *ProbeOverride) DeepCopy() *ProbeOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(ProbeOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ProbesConfig) DeepCopyInto(out *ProbesConfig) {
+	*out = *in
+	if in.Startup != nil {
+		in, out := &in.Startup, &out.Startup
+		*out = new(ProbeOverride)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Readiness != nil {
+		in, out := &in.Readiness, &out.Readiness
+		*out = new(ProbeOverride)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Liveness != nil {
+		in, out := &in.Liveness, &out.Liveness
+		*out = new(ProbeOverride)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ProbesConfig.
+func (in *ProbesConfig) DeepCopy() *ProbesConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ProbesConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *S3ModelSource) DeepCopyInto(out *S3ModelSource) {
+	*out = *in
+	if in.CredentialsSecretRef != nil {
+		in, out := &in.CredentialsSecretRef, &out.CredentialsSecretRef
+		*out = new(v1.LocalObjectReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new S3ModelSource.
+func (in *S3ModelSource) DeepCopy() *S3ModelSource {
+	if in == nil {
+		return nil
+	}
+	out := new(S3ModelSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SecretKeyRef) DeepCopyInto(out *SecretKeyRef) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecretKeyRef.
+func (in *SecretKeyRef) DeepCopy() *SecretKeyRef {
+	if in == nil {
+		return nil
+	}
+	out := new(SecretKeyRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ServiceConfig) DeepCopyInto(out *ServiceConfig) {
+	*out = *in
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ServiceConfig.
+func (in *ServiceConfig) DeepCopy() *ServiceConfig {
+	if in == nil {
+		return nil
+	}
+	out := new(ServiceConfig)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VLLMConfig) DeepCopyInto(out *VLLMConfig) {
 	*out = *in
+	if in.ServedModelName != nil {
+		in, out := &in.ServedModelName, &out.ServedModelName
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Seed != nil {
+		in, out := &in.Seed, &out.Seed
+		*out = new(int)
+		**out = **in
+	}
+	if in.ExtraArgs != nil {
+		in, out := &in.ExtraArgs, &out.ExtraArgs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ExtraArgsMap != nil {
+		in, out := &in.ExtraArgsMap, &out.ExtraArgsMap
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VLLMConfig.
@@ -140,12 +606,12 @@ func (in *VllmDeploymentSpec) DeepCopyInto(out *VllmDeploymentSpec) {
 	if in.Model != nil {
 		in, out := &in.Model, &out.Model
 		*out = new(ModelConfig)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.VLLMConfig != nil {
 		in, out := &in.VLLMConfig, &out.VLLMConfig
 		*out = new(VLLMConfig)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.Tolerations != nil {
 		in, out := &in.Tolerations, &out.Tolerations
@@ -168,6 +634,65 @@ func (in *VllmDeploymentSpec) DeepCopyInto(out *VllmDeploymentSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.Volumes != nil {
+		in, out := &in.Volumes, &out.Volumes
+		*out = make([]v1.Volume, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Affinity != nil {
+		in, out := &in.Affinity, &out.Affinity
+		*out = new(v1.Affinity)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ImagePullSecrets != nil {
+		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
+		*out = make([]v1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Service != nil {
+		in, out := &in.Service, &out.Service
+		*out = new(ServiceConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.GPU != nil {
+		in, out := &in.GPU, &out.GPU
+		*out = new(GPUConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.MultiNode != nil {
+		in, out := &in.MultiNode, &out.MultiNode
+		*out = new(MultiNodeConfig)
+		**out = **in
+	}
+	if in.Probes != nil {
+		in, out := &in.Probes, &out.Probes
+		*out = new(ProbesConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Autoscaling != nil {
+		in, out := &in.Autoscaling, &out.Autoscaling
+		*out = new(AutoscalingConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Idle != nil {
+		in, out := &in.Idle, &out.Idle
+		*out = new(IdleConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.IgnoreConfigChanges != nil {
+		in, out := &in.IgnoreConfigChanges, &out.IgnoreConfigChanges
+		*out = make([]ConfigReference, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VllmDeploymentSpec.
@@ -183,10 +708,22 @@ func (in *VllmDeploymentSpec) DeepCopy() *VllmDeploymentSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VllmDeploymentStatus) DeepCopyInto(out *VllmDeploymentStatus) {
 	*out = *in
+	if in.Autoscaler != nil {
+		in, out := &in.Autoscaler, &out.Autoscaler
+		*out = new(AutoscalerStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Idle != nil {
+		in, out := &in.Idle, &out.Idle
+		*out = new(IdleStatus)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
-		*out = make([]Condition, len(*in))
-		copy(*out, *in)
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
 	}
 }
 
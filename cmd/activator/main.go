@@ -0,0 +1,95 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	"github.com/revving-ai/vLLM-k8s-operator/internal/activator"
+)
+
+var setupLog = ctrl.Log.WithName("setup")
+
+func main() {
+	var port int
+	var metricsPort int
+	var backend string
+	var timeout time.Duration
+	flag.IntVar(&port, "port", 8000, "The port the requests are received on.")
+	flag.IntVar(&metricsPort, "metrics-port", 9090, "The port serving /metrics and /healthz.")
+	flag.StringVar(&backend, "backend", "", "The URL of the vLLM server the requests are forwarded to.")
+	flag.DurationVar(&timeout, "timeout", 15*time.Minute, "How long a request is held while the model starts.")
+	opts := zap.Options{}
+	opts.BindFlags(flag.CommandLine)
+	flag.Parse()
+
+	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
+
+	backendURL, err := url.Parse(backend)
+	if err != nil || backendURL.Host == "" {
+		setupLog.Error(err, "invalid --backend", "backend", backend)
+		os.Exit(1)
+	}
+	a := activator.New(backendURL, timeout)
+
+	metricsMux := http.NewServeMux()
+	metricsMux.Handle("/metrics", a.MetricsHandler())
+	metricsMux.HandleFunc("/healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	servers := []*http.Server{
+		{Addr: fmt.Sprintf(":%d", port), Handler: a, ReadHeaderTimeout: 10 * time.Second},
+		{Addr: fmt.Sprintf(":%d", metricsPort), Handler: metricsMux, ReadHeaderTimeout: 10 * time.Second},
+	}
+
+	ctx := ctrl.SetupSignalHandler()
+	errs := make(chan error, len(servers))
+	for _, server := range servers {
+		go func(server *http.Server) {
+			if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				errs <- err
+			}
+		}(server)
+	}
+
+	setupLog.Info("starting activator", "backend", backend)
+	select {
+	case err := <-errs:
+		setupLog.Error(err, "unable to serve")
+		os.Exit(1)
+	case <-ctx.Done():
+	}
+
+	// the held requests get until their own timeout to complete
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	for _, server := range servers {
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			setupLog.Error(err, "unable to shut down")
+		}
+	}
+}
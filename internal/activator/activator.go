@@ -0,0 +1,135 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+// Package activator implements the proxy receiving the requests of a
+// VllmDeployment scaled to zero. It holds each request until the vLLM server
+// reports ready, then forwards it. The operator scrapes the number of held
+// requests from its metrics endpoint to decide when to scale up.
+package activator
+
+import (
+	"context"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	// MetricRequestsPending is the gauge of the requests held until the
+	// model is ready.
+	MetricRequestsPending = "vllm_activator_requests_pending"
+	// MetricRequestsTotal is the counter of the requests received.
+	MetricRequestsTotal = "vllm_activator_requests_total"
+
+	// defaultHealthInterval is how often the health of the backend is
+	// checked while requests are held.
+	defaultHealthInterval = 2 * time.Second
+)
+
+// Activator is an http.Handler holding requests until the vLLM server behind
+// the backend URL is healthy and forwarding them to it.
+type Activator struct {
+	backend        *url.URL
+	timeout        time.Duration
+	healthInterval time.Duration
+	client         *http.Client
+	proxy          *httputil.ReverseProxy
+
+	registry *prometheus.Registry
+	pending  prometheus.Gauge
+	total    prometheus.Counter
+}
+
+// New returns an activator forwarding to the given backend, failing the
+// requests held longer than timeout.
+func New(backend *url.URL, timeout time.Duration) *Activator {
+	a := &Activator{
+		backend:        backend,
+		timeout:        timeout,
+		healthInterval: defaultHealthInterval,
+		client:         &http.Client{Timeout: defaultHealthInterval},
+		proxy:          httputil.NewSingleHostReverseProxy(backend),
+		registry:       prometheus.NewRegistry(),
+		pending: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: MetricRequestsPending,
+			Help: "Requests held until the model is ready.",
+		}),
+		total: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: MetricRequestsTotal,
+			Help: "Requests received by the activator.",
+		}),
+	}
+	// the responses are streamed as they are generated
+	a.proxy.FlushInterval = -1
+	a.registry.MustRegister(a.pending, a.total)
+	return a
+}
+
+// ServeHTTP holds the request until the backend is healthy and forwards it.
+func (a *Activator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.total.Inc()
+	a.pending.Inc()
+	ctx, cancel := context.WithTimeout(r.Context(), a.timeout)
+	err := a.waitForBackend(ctx)
+	cancel()
+	a.pending.Dec()
+	if err != nil {
+		http.Error(w, "the model did not become ready in time", http.StatusServiceUnavailable)
+		return
+	}
+	a.proxy.ServeHTTP(w, r)
+}
+
+// waitForBackend polls the /health endpoint of the backend until it returns
+// 200 or the context is done.
+func (a *Activator) waitForBackend(ctx context.Context) error {
+	health := a.backend.JoinPath("/health").String()
+	ticker := time.NewTicker(a.healthInterval)
+	defer ticker.Stop()
+	for {
+		if a.healthy(ctx, health) {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *Activator) healthy(ctx context.Context, url string) bool {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return false
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+	return resp.StatusCode == http.StatusOK
+}
+
+// MetricsHandler serves the metrics of the activator in the Prometheus text
+// format.
+func (a *Activator) MetricsHandler() http.Handler {
+	return promhttp.HandlerFor(a.registry, promhttp.HandlerOpts{})
+}
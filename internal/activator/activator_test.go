@@ -0,0 +1,97 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package activator
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Activator", func() {
+	var (
+		ready   atomic.Bool
+		backend *httptest.Server
+	)
+
+	BeforeEach(func() {
+		ready.Store(false)
+		backend = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !ready.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			if r.URL.Path == "/health" {
+				return
+			}
+			_, _ = io.WriteString(w, "completion for "+r.URL.Path)
+		}))
+		DeferCleanup(backend.Close)
+	})
+
+	newActivator := func(timeout time.Duration) *Activator {
+		backendURL, err := url.Parse(backend.URL)
+		Expect(err).NotTo(HaveOccurred())
+		a := New(backendURL, timeout)
+		a.healthInterval = 10 * time.Millisecond
+		return a
+	}
+
+	metrics := func(a *Activator) string {
+		rec := httptest.NewRecorder()
+		a.MetricsHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+		return rec.Body.String()
+	}
+
+	It("should hold a request until the backend is ready and forward it", func() {
+		a := newActivator(time.Minute)
+
+		done := make(chan *httptest.ResponseRecorder)
+		go func() {
+			rec := httptest.NewRecorder()
+			a.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/completions", strings.NewReader("{}")))
+			done <- rec
+		}()
+
+		Eventually(func() string { return metrics(a) }).Should(ContainSubstring(MetricRequestsPending + " 1"))
+		Consistently(done, 50*time.Millisecond).ShouldNot(Receive())
+
+		ready.Store(true)
+		var rec *httptest.ResponseRecorder
+		Eventually(done).Should(Receive(&rec))
+		Expect(rec.Code).To(Equal(http.StatusOK))
+		Expect(rec.Body.String()).To(Equal("completion for /v1/completions"))
+		Expect(metrics(a)).To(ContainSubstring(MetricRequestsPending + " 0"))
+		Expect(metrics(a)).To(ContainSubstring(MetricRequestsTotal + " 1"))
+	})
+
+	It("should fail a request when the backend is not ready in time", func() {
+		a := newActivator(50 * time.Millisecond)
+
+		rec := httptest.NewRecorder()
+		a.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+		Expect(rec.Code).To(Equal(http.StatusServiceUnavailable))
+		Expect(metrics(a)).To(ContainSubstring(MetricRequestsPending + " 0"))
+	})
+})
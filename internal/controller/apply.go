@@ -0,0 +1,135 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vllm "github.com/revving-ai/vLLM-k8s-operator/api/v1alpha1"
+)
+
+const (
+	// fieldManager is the field manager the operator applies its objects
+	// with. It owns the fields set by the operator, leaving the ones
+	// defaulted by the API server or set by other controllers alone.
+	fieldManager = "vllm-operator"
+	// handoverFieldManager keeps the value of a field the operator stops
+	// applying, until another controller takes it over.
+	handoverFieldManager = "vllm-operator-handover"
+)
+
+// apply server-side applies the given object owned by the given
+// vllmDeployment, after adjust, when not nil, has adapted it to the existing
+// object. The object is updated in place with the one read back from the
+// cluster. Only the fields set on the object are compared and changed, so
+// that applying an unchanged object is a no-op.
+func (r *VllmDeploymentReconciler) apply(ctx context.Context, v *vllm.VllmDeployment, obj client.Object,
+	adjust func(existing, desired client.Object) error) error {
+	log := log.FromContext(ctx)
+
+	gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+	if err != nil {
+		return err
+	}
+	if err := ctrl.SetControllerReference(v, obj, r.Scheme); err != nil {
+		return err
+	}
+
+	existing := newObjectOfKind(obj, gvk)
+	if err := r.Get(ctx, client.ObjectKeyFromObject(obj), existing); apierrors.IsNotFound(err) {
+		existing = nil
+	} else if err != nil {
+		return err
+	}
+	if existing != nil && adjust != nil {
+		if err := adjust(existing, obj); err != nil {
+			return err
+		}
+	}
+
+	// an apply patch carries the kind of the object and no resource version
+	obj.GetObjectKind().SetGroupVersionKind(gvk)
+	obj.SetResourceVersion("")
+	obj.SetManagedFields(nil)
+	if err := r.Patch(ctx, obj, client.Apply, client.FieldOwner(fieldManager), client.ForceOwnership); err != nil {
+		return err
+	}
+
+	switch {
+	case existing == nil:
+		log.Info("Created a new "+gvk.Kind, gvk.Kind+".Namespace", obj.GetNamespace(), gvk.Kind+".Name", obj.GetName())
+		r.recordObjectEvent(v, obj, vllm.EventReasonCreated, "Created")
+	case existing.GetResourceVersion() != obj.GetResourceVersion():
+		log.Info("Updated existing "+gvk.Kind, gvk.Kind+".Name", obj.GetName())
+		logChanges(log, gvk.Kind, existing, obj)
+		r.recordObjectEvent(v, obj, vllm.EventReasonUpdated, "Updated")
+	}
+	return nil
+}
+
+// handOverReplicas releases the replicas of the given existing workload object
+// from the field manager of the operator, which is about to stop applying
+// them for the autoscaler. The handover field manager applies their current
+// value first, so that they are not reset when the operator gives them up:
+// https://kubernetes.io/docs/reference/using-api/server-side-apply/#transferring-ownership
+func (r *VllmDeploymentReconciler) handOverReplicas(ctx context.Context, existing client.Object) error {
+	if !appliesReplicas(existing) {
+		return nil
+	}
+	gvk, err := apiutil.GVKForObject(existing, r.Scheme)
+	if err != nil {
+		return err
+	}
+	handover := &unstructured.Unstructured{}
+	handover.SetGroupVersionKind(gvk)
+	handover.SetName(existing.GetName())
+	handover.SetNamespace(existing.GetNamespace())
+	if err := unstructured.SetNestedField(handover.Object, int64(workloadReplicas(existing)), "spec", "replicas"); err != nil {
+		return err
+	}
+	log.FromContext(ctx).Info("Handing the replicas over to the autoscaler", gvk.Kind+".Name", existing.GetName())
+	return r.Patch(ctx, handover, client.Apply, client.FieldOwner(handoverFieldManager), client.ForceOwnership)
+}
+
+// appliesReplicas reports whether the field manager of the operator owns the
+// replicas of the given object.
+func appliesReplicas(obj client.Object) bool {
+	for _, entry := range obj.GetManagedFields() {
+		if entry.Manager != fieldManager || entry.Operation != metav1.ManagedFieldsOperationApply || entry.FieldsV1 == nil {
+			continue
+		}
+		var fields struct {
+			Spec map[string]any `json:"f:spec"`
+		}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil {
+			continue
+		}
+		if _, ok := fields.Spec["f:replicas"]; ok {
+			return true
+		}
+	}
+	return false
+}
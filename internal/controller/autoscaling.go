@@ -0,0 +1,210 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vllm "github.com/revving-ai/vLLM-k8s-operator/api/v1alpha1"
+)
+
+const (
+	// metricNumRequestsWaiting is the vLLM gauge of the requests waiting in the queue.
+	metricNumRequestsWaiting = "vllm:num_requests_waiting"
+	// metricNumRequestsRunning is the vLLM gauge of the requests being processed.
+	metricNumRequestsRunning = "vllm:num_requests_running"
+	// metricGPUCacheUsage is the vLLM gauge of the fraction of the KV cache in use.
+	metricGPUCacheUsage = "vllm:gpu_cache_usage_perc"
+	// metricTokensPerSecond is the rate of vllm:generation_tokens_total the
+	// metrics adapter is expected to serve.
+	metricTokensPerSecond = "vllm:generation_tokens_per_second"
+)
+
+// isAutoscaled reports whether the replicas are managed by an autoscaler
+// rather than spec.replicas.
+func isAutoscaled(v *vllm.VllmDeploymentSpec) bool {
+	return v.Autoscaling != nil
+}
+
+// usesHPA reports whether the replicas are scaled by a HorizontalPodAutoscaler.
+func usesHPA(v *vllm.VllmDeploymentSpec) bool {
+	return isAutoscaled(v) && v.Autoscaling.Provider != vllm.AutoscalingProviderOperator
+}
+
+// minReplicas returns the lower limit of the autoscaled replicas.
+func minReplicas(v *vllm.VllmDeploymentSpec) int32 {
+	if v.Autoscaling.MinReplicas != nil {
+		return *v.Autoscaling.MinReplicas
+	}
+	return vllm.DefaultReplicas
+}
+
+// horizontalPodAutoscalerName returns the name of the HPA of the given vllmDeployment.
+func horizontalPodAutoscalerName(v *vllm.VllmDeployment) string {
+	return fmt.Sprintf("%s-hpa", v.Name)
+}
+
+// scaleTargetRef returns the reference of the workload object scaled by the
+// autoscaler. A StatefulSet pair has no single object to scale.
+func scaleTargetRef(v *vllm.VllmDeployment, kind vllm.WorkloadKind) (autoscalingv2.CrossVersionObjectReference, error) {
+	switch {
+	case kind == vllm.WorkloadKindLeaderWorkerSet:
+		return autoscalingv2.CrossVersionObjectReference{
+			APIVersion: leaderWorkerSetGVK.GroupVersion().String(),
+			Kind:       leaderWorkerSetGVK.Kind,
+			Name:       leaderWorkerSetName(v),
+		}, nil
+	case kind == vllm.WorkloadKindStatefulSet && isMultiNode(&v.Spec):
+		return autoscalingv2.CrossVersionObjectReference{},
+			errors.New("spec.autoscaling needs a LeaderWorkerSet to scale spec.multiNode groups")
+	case kind == vllm.WorkloadKindStatefulSet:
+		return autoscalingv2.CrossVersionObjectReference{
+			APIVersion: appsv1.SchemeGroupVersion.String(),
+			Kind:       "StatefulSet",
+			Name:       statefulSetName(v),
+		}, nil
+	}
+	return autoscalingv2.CrossVersionObjectReference{
+		APIVersion: appsv1.SchemeGroupVersion.String(),
+		Kind:       "Deployment",
+		Name:       deploymentName(v),
+	}, nil
+}
+
+// constructMetricSpecs returns the HPA metrics for the targets of the given
+// autoscaling config, in the order of its fields.
+func constructMetricSpecs(v *vllm.VllmDeployment) []autoscalingv2.MetricSpec {
+	as := v.Spec.Autoscaling
+	targets := []struct {
+		target      *vllm.MetricTarget
+		defaultName string
+	}{
+		{as.NumRequestsWaiting, metricNumRequestsWaiting},
+		{as.NumRequestsRunning, metricNumRequestsRunning},
+		{as.GPUCacheUsage, metricGPUCacheUsage},
+		{as.TokensPerSecond, metricTokensPerSecond},
+	}
+
+	var metrics []autoscalingv2.MetricSpec
+	for _, t := range targets {
+		if t.target == nil {
+			continue
+		}
+		name := t.defaultName
+		if t.target.MetricName != "" {
+			name = t.target.MetricName
+		}
+		averageValue := t.target.AverageValue.DeepCopy()
+		target := autoscalingv2.MetricTarget{
+			Type:         autoscalingv2.AverageValueMetricType,
+			AverageValue: &averageValue,
+		}
+
+		if as.MetricType == vllm.AutoscalingMetricTypeExternal {
+			metrics = append(metrics, autoscalingv2.MetricSpec{
+				Type: autoscalingv2.ExternalMetricSourceType,
+				External: &autoscalingv2.ExternalMetricSource{
+					Metric: autoscalingv2.MetricIdentifier{
+						Name:     name,
+						Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": v.Name}},
+					},
+					Target: target,
+				},
+			})
+			continue
+		}
+		metrics = append(metrics, autoscalingv2.MetricSpec{
+			Type: autoscalingv2.PodsMetricSourceType,
+			Pods: &autoscalingv2.PodsMetricSource{
+				Metric: autoscalingv2.MetricIdentifier{Name: name},
+				Target: target,
+			},
+		})
+	}
+	return metrics
+}
+
+// constructHorizontalPodAutoscaler constructs the HPA scaling the workload of
+// the given kind on the vLLM metrics.
+func constructHorizontalPodAutoscaler(v *vllm.VllmDeployment, kind vllm.WorkloadKind) (*autoscalingv2.HorizontalPodAutoscaler, error) {
+	target, err := scaleTargetRef(v, kind)
+	if err != nil {
+		return nil, err
+	}
+	minReplicas := minReplicas(&v.Spec)
+	return &autoscalingv2.HorizontalPodAutoscaler{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      horizontalPodAutoscalerName(v),
+			Namespace: v.Namespace,
+			Labels:    podLabels(v),
+		},
+		Spec: autoscalingv2.HorizontalPodAutoscalerSpec{
+			ScaleTargetRef: target,
+			MinReplicas:    &minReplicas,
+			MaxReplicas:    v.Spec.Autoscaling.MaxReplicas,
+			Metrics:        constructMetricSpecs(v),
+			Behavior:       v.Spec.Autoscaling.Behavior,
+		},
+	}, nil
+}
+
+// reconcileAutoscaler applies the HPA of the given vllmDeployment
+// while the HPA provider is configured, and deletes it otherwise.
+func (r *VllmDeploymentReconciler) reconcileAutoscaler(ctx context.Context, v *vllm.VllmDeployment, kind vllm.WorkloadKind) error {
+	log := log.FromContext(ctx)
+
+	if !usesHPA(&v.Spec) {
+		var existing autoscalingv2.HorizontalPodAutoscaler
+		err := r.Get(ctx, client.ObjectKey{Namespace: v.Namespace, Name: horizontalPodAutoscalerName(v)}, &existing)
+		if err != nil {
+			return client.IgnoreNotFound(err)
+		}
+		if !metav1.IsControlledBy(&existing, v) {
+			return nil
+		}
+		log.Info("Deleting the HorizontalPodAutoscaler", "HorizontalPodAutoscaler.Name", existing.Name)
+		return client.IgnoreNotFound(r.Delete(ctx, &existing))
+	}
+
+	desired, err := constructHorizontalPodAutoscaler(v, kind)
+	if err != nil {
+		return err
+	}
+	return r.apply(ctx, v, desired, nil)
+}
+
+// omitReplicas leaves the replicas out of the given desired workload object,
+// so that applying it leaves the count set by the autoscaler alone.
+func omitReplicas(desired client.Object) {
+	switch desired := desired.(type) {
+	case *appsv1.Deployment:
+		desired.Spec.Replicas = nil
+	case *appsv1.StatefulSet:
+		desired.Spec.Replicas = nil
+	case *unstructured.Unstructured:
+		unstructured.RemoveNestedField(desired.Object, "spec", "replicas")
+	}
+}
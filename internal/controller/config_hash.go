@@ -0,0 +1,217 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sort"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	vllm "github.com/revving-ai/vLLM-k8s-operator/api/v1alpha1"
+)
+
+// configHashAnnotation is the pod template annotation holding the hash of the
+// ConfigMaps and Secrets read by the vLLM pods, so that changing them rolls
+// out the pods.
+const configHashAnnotation = "vllmoperator.org/config-hash"
+
+// configReferences returns the ConfigMaps and Secrets read by the vLLM pods of
+// the given vllmDeployment through their volumes and environment, sorted and
+// less the ones listed in spec.ignoreConfigChanges.
+func configReferences(v *vllm.VllmDeployment) []vllm.ConfigReference {
+	template := constructPodTemplate(v, podLabels(v))
+
+	seen := map[vllm.ConfigReference]bool{}
+	add := func(kind vllm.ConfigReferenceKind, name string) {
+		if name != "" {
+			seen[vllm.ConfigReference{Kind: kind, Name: name}] = true
+		}
+	}
+	for _, volume := range template.Spec.Volumes {
+		if volume.ConfigMap != nil {
+			add(vllm.ConfigReferenceConfigMap, volume.ConfigMap.Name)
+		}
+		if volume.Secret != nil {
+			add(vllm.ConfigReferenceSecret, volume.Secret.SecretName)
+		}
+		if volume.Projected == nil {
+			continue
+		}
+		for _, source := range volume.Projected.Sources {
+			if source.ConfigMap != nil {
+				add(vllm.ConfigReferenceConfigMap, source.ConfigMap.Name)
+			}
+			if source.Secret != nil {
+				add(vllm.ConfigReferenceSecret, source.Secret.Name)
+			}
+		}
+	}
+	containers := append(append([]corev1.Container{}, template.Spec.InitContainers...), template.Spec.Containers...)
+	for _, c := range containers {
+		for _, envFrom := range c.EnvFrom {
+			if envFrom.ConfigMapRef != nil {
+				add(vllm.ConfigReferenceConfigMap, envFrom.ConfigMapRef.Name)
+			}
+			if envFrom.SecretRef != nil {
+				add(vllm.ConfigReferenceSecret, envFrom.SecretRef.Name)
+			}
+		}
+		for _, env := range c.Env {
+			if env.ValueFrom == nil {
+				continue
+			}
+			if env.ValueFrom.ConfigMapKeyRef != nil {
+				add(vllm.ConfigReferenceConfigMap, env.ValueFrom.ConfigMapKeyRef.Name)
+			}
+			if env.ValueFrom.SecretKeyRef != nil {
+				add(vllm.ConfigReferenceSecret, env.ValueFrom.SecretKeyRef.Name)
+			}
+		}
+	}
+	for _, ref := range v.Spec.IgnoreConfigChanges {
+		delete(seen, ref)
+	}
+
+	refs := make([]vllm.ConfigReference, 0, len(seen))
+	for ref := range seen {
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Kind != refs[j].Kind {
+			return refs[i].Kind < refs[j].Kind
+		}
+		return refs[i].Name < refs[j].Name
+	})
+	return refs
+}
+
+// configHash returns the hash of the content of the ConfigMaps and Secrets
+// read by the vLLM pods of the given vllmDeployment, or an empty string when
+// they read none. A missing object is hashed as such, so that creating it
+// rolls out the pods as well.
+func (r *VllmDeploymentReconciler) configHash(ctx context.Context, v *vllm.VllmDeployment) (string, error) {
+	refs := configReferences(v)
+	if len(refs) == 0 {
+		return "", nil
+	}
+
+	h := sha256.New()
+	for _, ref := range refs {
+		key := client.ObjectKey{Name: ref.Name, Namespace: v.Namespace}
+		data := map[string][]byte{}
+		var err error
+		switch ref.Kind {
+		case vllm.ConfigReferenceConfigMap:
+			var configMap corev1.ConfigMap
+			if err = r.Get(ctx, key, &configMap); err == nil {
+				for k, val := range configMap.Data {
+					data[k] = []byte(val)
+				}
+				for k, val := range configMap.BinaryData {
+					data[k] = val
+				}
+			}
+		case vllm.ConfigReferenceSecret:
+			var secret corev1.Secret
+			if err = r.Get(ctx, key, &secret); err == nil {
+				data = secret.Data
+			}
+		}
+		if apierrors.IsNotFound(err) {
+			fmt.Fprintf(h, "%s/%s missing\n", ref.Kind, ref.Name)
+			continue
+		} else if err != nil {
+			return "", err
+		}
+
+		fmt.Fprintf(h, "%s/%s\n", ref.Kind, ref.Name)
+		keys := make([]string, 0, len(data))
+		for k := range data {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(h, "%s %d\n", k, len(data[k]))
+			h.Write(data[k])
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// setPodTemplateAnnotation sets the given annotation on the pod templates of
+// the given workload object.
+func setPodTemplateAnnotation(obj client.Object, key, value string) {
+	var template *corev1.PodTemplateSpec
+	switch obj := obj.(type) {
+	case *appsv1.Deployment:
+		template = &obj.Spec.Template
+	case *appsv1.StatefulSet:
+		template = &obj.Spec.Template
+	case *unstructured.Unstructured:
+		for _, name := range []string{"leaderTemplate", "workerTemplate"} {
+			if _, found, _ := unstructured.NestedMap(obj.Object, "spec", "leaderWorkerTemplate", name); found {
+				_ = unstructured.SetNestedField(obj.Object, value, "spec", "leaderWorkerTemplate", name, "metadata", "annotations", key)
+			}
+		}
+		return
+	default:
+		return
+	}
+	if template.Annotations == nil {
+		template.Annotations = map[string]string{}
+	}
+	template.Annotations[key] = value
+}
+
+// vllmDeploymentsReading returns a function mapping an object of the given
+// kind to the vllmDeployments whose pods read it, to roll them out when it
+// changes.
+func (r *VllmDeploymentReconciler) vllmDeploymentsReading(kind vllm.ConfigReferenceKind) handler.MapFunc {
+	return func(ctx context.Context, obj client.Object) []reconcile.Request {
+		var vllmDeployments vllm.VllmDeploymentList
+		if err := r.List(ctx, &vllmDeployments, client.InNamespace(obj.GetNamespace())); err != nil {
+			log.FromContext(ctx).Error(err, "Failed to list the VllmDeployments reading a "+string(kind), string(kind)+".Name", obj.GetName())
+			return nil
+		}
+
+		ref := vllm.ConfigReference{Kind: kind, Name: obj.GetName()}
+		var requests []reconcile.Request
+		for i := range vllmDeployments.Items {
+			v := &vllmDeployments.Items[i]
+			// the pod template of an invalid spec cannot be constructed
+			if validateSpec(&v.Spec) != nil {
+				continue
+			}
+			if slices.Contains(configReferences(v), ref) {
+				requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(v)})
+			}
+		}
+		return requests
+	}
+}
@@ -0,0 +1,104 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	vllm "github.com/revving-ai/vLLM-k8s-operator/api/v1alpha1"
+)
+
+const (
+	// hfTokenEnvName is the environment variable Hugging Face libraries read the token from.
+	hfTokenEnvName = "HF_TOKEN"
+	// defaultTokenSecretKey is the Secret key used when tokenSecretRef.key is empty.
+	defaultTokenSecretKey = "token"
+	// credentialsPollInterval is how often a missing token Secret is checked again.
+	credentialsPollInterval = 30 * time.Second
+)
+
+// tokenSecretKey returns the key of the Secret holding the Hugging Face token.
+func tokenSecretKey(ref *vllm.SecretKeyRef) string {
+	if ref.Key != "" {
+		return ref.Key
+	}
+	return defaultTokenSecretKey
+}
+
+// hfTokenEnvVar returns the HF_TOKEN environment variable read from the Secret
+// referenced by the spec, or nil when no token is configured.
+func hfTokenEnvVar(v *vllm.VllmDeploymentSpec) *corev1.EnvVar {
+	if v.Model == nil || v.Model.TokenSecretRef == nil {
+		return nil
+	}
+	ref := v.Model.TokenSecretRef
+	return &corev1.EnvVar{
+		Name: hfTokenEnvName,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: ref.Name},
+				Key:                  tokenSecretKey(ref),
+			},
+		},
+	}
+}
+
+// checkModelCredentials sets the CredentialsMissing condition on the given
+// vllmDeployment depending on whether the referenced token Secret and key exist.
+func (r *VllmDeploymentReconciler) checkModelCredentials(ctx context.Context, v *vllm.VllmDeployment) error {
+	if v.Spec.Model.TokenSecretRef == nil {
+		meta.RemoveStatusCondition(&v.Status.Conditions, vllm.ConditionCredentialsMissing)
+		return nil
+	}
+	ref := v.Spec.Model.TokenSecretRef
+	key := tokenSecretKey(ref)
+
+	cond := metav1.Condition{
+		Type:               vllm.ConditionCredentialsMissing,
+		Status:             metav1.ConditionFalse,
+		Reason:             vllm.ReasonCredentialsFound,
+		ObservedGeneration: v.Generation,
+	}
+
+	var secret corev1.Secret
+	err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: v.Namespace}, &secret)
+	switch {
+	case apierrors.IsNotFound(err):
+		cond.Status = metav1.ConditionTrue
+		cond.Reason = vllm.ReasonSecretNotFound
+		cond.Message = fmt.Sprintf("Secret %s not found", ref.Name)
+	case err != nil:
+		return err
+	default:
+		if _, ok := secret.Data[key]; !ok {
+			cond.Status = metav1.ConditionTrue
+			cond.Reason = vllm.ReasonSecretKeyNotFound
+			cond.Message = fmt.Sprintf("Secret %s has no key %s", ref.Name, key)
+		}
+	}
+
+	meta.SetStatusCondition(&v.Status.Conditions, cond)
+	return nil
+}
@@ -0,0 +1,114 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// debugLevel is the verbosity of the logs auditing the updates of the owned
+// objects, shown with --zap-log-level=debug.
+const debugLevel = 1
+
+// fieldChange is a field of an owned object changed by an update.
+type fieldChange struct {
+	Path string `json:"path"`
+	Old  any    `json:"old,omitempty"`
+	New  any    `json:"new,omitempty"`
+}
+
+// diffObjects returns the fields that differ between the existing and the
+// updated version of an object, sorted by path. The status and the metadata
+// maintained by the API server are left out.
+func diffObjects(existing, updated runtime.Object) ([]fieldChange, error) {
+	before, err := runtime.DefaultUnstructuredConverter.ToUnstructured(existing)
+	if err != nil {
+		return nil, err
+	}
+	after, err := runtime.DefaultUnstructuredConverter.ToUnstructured(updated)
+	if err != nil {
+		return nil, err
+	}
+	for _, content := range []map[string]any{before, after} {
+		delete(content, "status")
+		if metadata, ok := content["metadata"].(map[string]any); ok {
+			for _, field := range []string{"resourceVersion", "generation", "managedFields", "creationTimestamp", "uid"} {
+				delete(metadata, field)
+			}
+		}
+	}
+
+	var changes []fieldChange
+	diffValues("", before, after, &changes)
+	return changes, nil
+}
+
+// diffValues appends to changes the fields under path that differ between
+// the old and the new value. Maps and lists of the same length are walked
+// down to the changed leaves; other values are compared as a whole.
+func diffValues(path string, old, new any, changes *[]fieldChange) {
+	switch o := old.(type) {
+	case map[string]any:
+		if n, ok := new.(map[string]any); ok {
+			keys := make([]string, 0, len(o)+len(n))
+			for k := range o {
+				keys = append(keys, k)
+			}
+			for k := range n {
+				if _, ok := o[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				diffValues(path+"."+k, o[k], n[k], changes)
+			}
+			return
+		}
+	case []any:
+		if n, ok := new.([]any); ok && len(o) == len(n) {
+			for i := range o {
+				diffValues(fmt.Sprintf("%s[%d]", path, i), o[i], n[i], changes)
+			}
+			return
+		}
+	}
+	if !reflect.DeepEqual(old, new) {
+		*changes = append(*changes, fieldChange{Path: path, Old: old, New: new})
+	}
+}
+
+// logChanges logs at debug level the fields changed by the update of an owned
+// object of the given kind, to audit why the operator updates it.
+func logChanges(log logr.Logger, kind string, existing, updated client.Object) {
+	debug := log.V(debugLevel)
+	if !debug.Enabled() {
+		return
+	}
+	changes, err := diffObjects(existing, updated)
+	if err != nil {
+		debug.Info("Failed to diff "+kind, kind+".Name", updated.GetName(), "error", err.Error())
+		return
+	}
+	debug.Info("Fields changed in "+kind, kind+".Name", updated.GetName(), "changes", changes)
+}
@@ -0,0 +1,184 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"sync"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+
+	vllm "github.com/revving-ai/vLLM-k8s-operator/api/v1alpha1"
+)
+
+// crashLoopBackOff is the waiting reason of a container restarted too often.
+const crashLoopBackOff = "CrashLoopBackOff"
+
+// recordObjectEvent records that the operator created or updated the given
+// object of the given vllmDeployment.
+func (r *VllmDeploymentReconciler) recordObjectEvent(v *vllm.VllmDeployment, obj client.Object, reason, verb string) {
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	if gvk, err := apiutil.GVKForObject(obj, r.Scheme); err == nil {
+		kind = gvk.Kind
+	}
+	r.recorder.Eventf(v, corev1.EventTypeNormal, reason, "%s %s %s", verb, kind, obj.GetName())
+}
+
+// recordScaling records that the replicas of the given workload object went
+// from one count to another, for the given reason.
+func (r *VllmDeploymentReconciler) recordScaling(v *vllm.VllmDeployment, obj client.Object, from, to int32, reason string) {
+	eventReason := vllm.EventReasonScaledUp
+	if to < from {
+		eventReason = vllm.EventReasonScaledDown
+	}
+	r.recorder.Eventf(v, corev1.EventTypeNormal, eventReason, "Scaled %s from %d to %d replicas: %s",
+		obj.GetName(), from, to, reason)
+}
+
+// recordStatusEvents records the transitions between the previous and the
+// current status of the given vllmDeployment, and the containers of its pods
+// crash looping since they last restarted.
+func (r *VllmDeploymentReconciler) recordStatusEvents(v *vllm.VllmDeployment, previous, current *vllm.VllmDeploymentStatus, pods []corev1.Pod) {
+	if becameTrue(previous, current, vllm.ConditionReady) && current.ReadyReplicas > 0 {
+		r.recorder.Eventf(v, corev1.EventTypeNormal, vllm.EventReasonRolloutComplete,
+			"Rollout complete: %d/%d replicas ready", current.ReadyReplicas, current.Replicas)
+	}
+	if becameTrue(previous, current, vllm.ConditionDegraded) {
+		c := meta.FindStatusCondition(current.Conditions, vllm.ConditionDegraded)
+		r.recorder.Event(v, corev1.EventTypeWarning, vllm.EventReasonRolloutFailed, c.Message)
+	}
+
+	if becameTrue(previous, current, vllm.ConditionPaused) {
+		c := meta.FindStatusCondition(current.Conditions, vllm.ConditionPaused)
+		r.recorder.Event(v, corev1.EventTypeNormal, vllm.EventReasonPaused, c.Message)
+	}
+	// true in the previous status and not anymore
+	if becameTrue(current, previous, vllm.ConditionPaused) {
+		r.recorder.Event(v, corev1.EventTypeNormal, vllm.EventReasonResumed, "Reconciliation resumed")
+	}
+
+	if c := meta.FindStatusCondition(current.Conditions, vllm.ConditionModelDownloaded); c != nil && c.Reason == vllm.ReasonDownloadFailed {
+		if p := meta.FindStatusCondition(previous.Conditions, vllm.ConditionModelDownloaded); p == nil || p.Reason != vllm.ReasonDownloadFailed {
+			r.recorder.Event(v, corev1.EventTypeWarning, vllm.EventReasonModelDownloadFailed, c.Message)
+		}
+	}
+
+	if current.Restarts <= previous.Restarts {
+		return
+	}
+	var loops []crashLoop
+	for i := range pods {
+		loops = append(loops, crashLoopingContainers(&pods[i])...)
+	}
+	for _, loop := range r.crashLoops.unreported(client.ObjectKeyFromObject(v), loops) {
+		r.recorder.Event(v, corev1.EventTypeWarning, vllm.EventReasonPodCrashLooping, loop.message)
+	}
+}
+
+// becameTrue reports whether the given condition is True in the current
+// status and was not in the previous one.
+func becameTrue(previous, current *vllm.VllmDeploymentStatus, conditionType string) bool {
+	return meta.IsStatusConditionTrue(current.Conditions, conditionType) &&
+		!meta.IsStatusConditionTrue(previous.Conditions, conditionType)
+}
+
+// containerRestarts returns the number of restarts of the containers of the
+// given pods.
+func containerRestarts(pods []corev1.Pod) int32 {
+	var restarts int32
+	for i := range pods {
+		statuses := append(append([]corev1.ContainerStatus{}, pods[i].Status.InitContainerStatuses...), pods[i].Status.ContainerStatuses...)
+		for _, s := range statuses {
+			restarts += s.RestartCount
+		}
+	}
+	return restarts
+}
+
+// crashLoop is a container of a vLLM pod waiting in CrashLoopBackOff.
+type crashLoop struct {
+	// container identifies the container across pods recreated with the
+	// same name.
+	container string
+	restarts  int32
+	message   string
+}
+
+// crashLoopingContainers returns the containers of the given pod waiting in
+// CrashLoopBackOff, described with their restart count.
+func crashLoopingContainers(pod *corev1.Pod) []crashLoop {
+	var loops []crashLoop
+	statuses := append(append([]corev1.ContainerStatus{}, pod.Status.InitContainerStatuses...), pod.Status.ContainerStatuses...)
+	for _, s := range statuses {
+		if s.State.Waiting == nil || s.State.Waiting.Reason != crashLoopBackOff {
+			continue
+		}
+		message := fmt.Sprintf("Container %s of pod %s is crash looping after %d restarts", s.Name, pod.Name, s.RestartCount)
+		if t := s.LastTerminationState.Terminated; t != nil {
+			message += fmt.Sprintf(", last exit code %d (%s)", t.ExitCode, t.Reason)
+		}
+		loops = append(loops, crashLoop{container: string(pod.UID) + "/" + s.Name, restarts: s.RestartCount, message: message})
+	}
+	return loops
+}
+
+// crashLoopTracker remembers, per vllmDeployment, the restart count at which
+// each crash-looping container was last reported. It is kept in memory, so
+// after a restart of the operator the containers still crash looping are
+// reported again on their next restart.
+type crashLoopTracker struct {
+	mu       sync.Mutex
+	reported map[types.NamespacedName]map[string]int32
+}
+
+// unreported returns the given crash loops of the given vllmDeployment that
+// were not reported at their restart count yet, and remembers them as
+// reported. The containers no longer crash looping are forgotten.
+func (t *crashLoopTracker) unreported(key types.NamespacedName, loops []crashLoop) []crashLoop {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.reported == nil {
+		t.reported = map[types.NamespacedName]map[string]int32{}
+	}
+
+	previous := t.reported[key]
+	current := make(map[string]int32, len(loops))
+	var unreported []crashLoop
+	for _, loop := range loops {
+		if restarts, ok := previous[loop.container]; !ok || restarts != loop.restarts {
+			unreported = append(unreported, loop)
+		}
+		current[loop.container] = loop.restarts
+	}
+	if len(current) == 0 {
+		delete(t.reported, key)
+	} else {
+		t.reported[key] = current
+	}
+	return unreported
+}
+
+// forget drops what was reported of the given vllmDeployment.
+func (t *crashLoopTracker) forget(key types.NamespacedName) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.reported, key)
+}
@@ -0,0 +1,172 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vllm "github.com/revving-ai/vLLM-k8s-operator/api/v1alpha1"
+)
+
+const (
+	// cleanupFinalizer holds the deletion of a vllmDeployment until its
+	// traffic is drained and the objects the garbage collector does not
+	// handle are cleaned up.
+	cleanupFinalizer = "vllmoperator.org/cleanup"
+	// ephemeralAnnotation marks an existing model cache claim to be deleted
+	// with the vllmDeployment using it.
+	ephemeralAnnotation = "vllmoperator.org/ephemeral"
+	// drainTimeout bounds how long the deletion waits for the vLLM pods to
+	// terminate before cleaning up anyway.
+	drainTimeout = 5 * time.Minute
+	// drainPollInterval is how often the terminating pods are checked.
+	drainPollInterval = 5 * time.Second
+)
+
+// deletionPolicy returns what happens to the model cache on deletion.
+func deletionPolicy(v *vllm.VllmDeploymentSpec) vllm.DeletionPolicy {
+	if v.DeletionPolicy == "" {
+		return vllm.DeletionPolicyDelete
+	}
+	return v.DeletionPolicy
+}
+
+// finalize cleans up after the deletion of the given vllmDeployment: it
+// drains the traffic, applies the deletion policy to the model cache and
+// removes the finalizer, releasing the vllmDeployment. It requeues while the
+// vLLM pods terminate.
+func (r *VllmDeploymentReconciler) finalize(ctx context.Context, v *vllm.VllmDeployment) (ctrl.Result, error) {
+	if !controllerutil.ContainsFinalizer(v, cleanupFinalizer) {
+		return ctrl.Result{}, nil
+	}
+	log := log.FromContext(ctx)
+
+	drained, err := r.drain(ctx, v)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if !drained {
+		return ctrl.Result{RequeueAfter: drainPollInterval}, nil
+	}
+
+	if err := r.cleanupModelCache(ctx, v); err != nil {
+		return ctrl.Result{}, err
+	}
+	key := client.ObjectKeyFromObject(v)
+	if r.autoscaler != nil {
+		r.autoscaler.forget(key)
+		r.autoscaler.forgetIdle(key)
+	}
+	r.crashLoops.forget(key)
+
+	log.Info("Cleanup complete, removing the finalizer")
+	r.recorder.Event(v, corev1.EventTypeNormal, vllm.EventReasonCleanupComplete, "Traffic drained and resources cleaned up")
+	controllerutil.RemoveFinalizer(v, cleanupFinalizer)
+	return ctrl.Result{}, client.IgnoreNotFound(r.Update(ctx, v))
+}
+
+// drain stops sending requests to the given vllmDeployment by deleting its
+// Services and activator, then deletes its workload so that the vLLM pods
+// finish their requests within their termination grace period. It reports
+// whether the pods are gone, or the drain timed out. The Draining event is
+// recorded once, when the workload is deleted.
+func (r *VllmDeploymentReconciler) drain(ctx context.Context, v *vllm.VllmDeployment) (bool, error) {
+	frontends := []client.Object{
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: serviceName(v), Namespace: v.Namespace}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: backendServiceName(v), Namespace: v.Namespace}},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: activatorName(v), Namespace: v.Namespace}},
+		&autoscalingv2.HorizontalPodAutoscaler{ObjectMeta: metav1.ObjectMeta{Name: horizontalPodAutoscalerName(v), Namespace: v.Namespace}},
+	}
+	if _, err := r.deleteOwnedObjects(ctx, v, frontends...); err != nil {
+		return false, err
+	}
+	started, err := r.deleteOwnedObjects(ctx, v, workloadObjects(v)...)
+	if err != nil {
+		return false, err
+	}
+
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(v.Namespace), client.MatchingLabels{"app": v.Name}); err != nil {
+		return false, err
+	}
+	if len(pods.Items) == 0 {
+		return true, nil
+	}
+	if time.Since(v.DeletionTimestamp.Time) > drainTimeout {
+		log.FromContext(ctx).Info("Drain timed out, cleaning up with pods still terminating", "pods", len(pods.Items))
+		return true, nil
+	}
+	if started {
+		r.recorder.Eventf(v, corev1.EventTypeNormal, vllm.EventReasonDraining,
+			"Waiting for %d pods to finish their requests and terminate", len(pods.Items))
+	}
+	return false, nil
+}
+
+// cleanupModelCache applies the deletion policy to the model cache claim: the
+// claim provisioned by the operator is deleted, or released from the
+// vllmDeployment so that the garbage collector keeps it; an existing claim is
+// only deleted when annotated as ephemeral.
+func (r *VllmDeploymentReconciler) cleanupModelCache(ctx context.Context, v *vllm.VllmDeployment) error {
+	if !hasModelCache(&v.Spec) {
+		return nil
+	}
+	log := log.FromContext(ctx)
+
+	var claim corev1.PersistentVolumeClaim
+	key := client.ObjectKey{Name: modelCacheClaimName(v), Namespace: v.Namespace}
+	if err := r.Get(ctx, key, &claim); err != nil {
+		return client.IgnoreNotFound(err)
+	}
+	provisioned := metav1.IsControlledBy(&claim, v)
+
+	switch {
+	case deletionPolicy(&v.Spec) == vllm.DeletionPolicyRetain && provisioned:
+		log.Info("Retaining the model cache", "PersistentVolumeClaim.Name", claim.Name)
+		ownerReferences := claim.OwnerReferences[:0]
+		for _, ref := range claim.OwnerReferences {
+			if ref.UID != v.UID {
+				ownerReferences = append(ownerReferences, ref)
+			}
+		}
+		claim.OwnerReferences = ownerReferences
+		if err := r.Update(ctx, &claim); err != nil {
+			return err
+		}
+		r.recorder.Eventf(v, corev1.EventTypeNormal, vllm.EventReasonModelCacheRetained,
+			"Kept the model cache %s, reuse it with model.cache.existingClaim", claim.Name)
+
+	case deletionPolicy(&v.Spec) == vllm.DeletionPolicyDelete && (provisioned || claim.Annotations[ephemeralAnnotation] == "true"):
+		log.Info("Deleting the model cache", "PersistentVolumeClaim.Name", claim.Name)
+		if err := r.Delete(ctx, &claim); err != nil && !apierrors.IsNotFound(err) {
+			return err
+		}
+		r.recorder.Eventf(v, corev1.EventTypeNormal, vllm.EventReasonModelCacheDeleted, "Deleted the model cache %s", claim.Name)
+	}
+	return nil
+}
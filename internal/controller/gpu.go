@@ -0,0 +1,153 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	vllm "github.com/revving-ai/vLLM-k8s-operator/api/v1alpha1"
+)
+
+const (
+	// sharedMemoryVolumeName is the name of the memory-backed volume mounted at /dev/shm.
+	sharedMemoryVolumeName = "dshm"
+	sharedMemoryMountPath  = "/dev/shm"
+)
+
+// gpuResourceName returns the extended resource requested for the GPUs.
+func gpuResourceName(gpu *vllm.GPUConfig) corev1.ResourceName {
+	if gpu.ResourceName == "" {
+		return vllm.DefaultGPUResourceName
+	}
+	return gpu.ResourceName
+}
+
+// tensorParallelSize returns the value of --tensor-parallel-size: the one set
+// in the vLLM config, or the GPUs of the pod, or of the whole multi-node
+// group, split across the pipeline stages. It returns 0 when the flag is left
+// to vLLM.
+func tensorParallelSize(v *vllm.VllmDeploymentSpec) int {
+	if v.VLLMConfig.TensorParallelSize != 0 {
+		return v.VLLMConfig.TensorParallelSize
+	}
+	if v.GPU == nil {
+		return 0
+	}
+	count := int(v.GPU.Count)
+	total := count
+	if isMultiNode(v) {
+		total *= int(v.MultiNode.Size)
+	}
+	if pp := pipelineParallelSize(v); pp > 1 && total%pp == 0 {
+		return total / pp
+	}
+	if count <= 1 {
+		return 0
+	}
+	return count
+}
+
+// injectGPU requests the GPUs for the vllm container, mounts a memory-backed
+// /dev/shm for NCCL and schedules the pod on nodes with the requested product.
+func injectGPU(v *vllm.VllmDeploymentSpec, podSpec *corev1.PodSpec, vllmContainer *corev1.Container) {
+	gpu := v.GPU
+	if gpu == nil {
+		return
+	}
+
+	count := *resource.NewQuantity(int64(gpu.Count), resource.DecimalSI)
+	if vllmContainer.Resources.Limits == nil {
+		vllmContainer.Resources.Limits = corev1.ResourceList{}
+	}
+	vllmContainer.Resources.Limits[gpuResourceName(gpu)] = count
+	// extended resources cannot be overcommitted, a request must equal the limit
+	if _, ok := vllmContainer.Resources.Requests[gpuResourceName(gpu)]; ok {
+		vllmContainer.Resources.Requests[gpuResourceName(gpu)] = count
+	}
+
+	podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+		Name: sharedMemoryVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{
+				Medium:    corev1.StorageMediumMemory,
+				SizeLimit: gpu.SharedMemorySize,
+			},
+		},
+	})
+	vllmContainer.VolumeMounts = append(vllmContainer.VolumeMounts, corev1.VolumeMount{
+		Name:      sharedMemoryVolumeName,
+		MountPath: sharedMemoryMountPath,
+	})
+
+	if gpu.Count > 1 {
+		// surface NCCL errors in the logs, unless the container sets its own level
+		if !hasEnvVar(vllmContainer.Env, "NCCL_DEBUG") {
+			vllmContainer.Env = append(vllmContainer.Env, corev1.EnvVar{Name: "NCCL_DEBUG", Value: "WARN"})
+		}
+	}
+
+	if gpu.Product != "" {
+		podSpec.Affinity = withRequiredNodeSelector(podSpec.Affinity, corev1.NodeSelectorRequirement{
+			Key:      gpuProductLabel(gpu),
+			Operator: corev1.NodeSelectorOpIn,
+			Values:   []string{gpu.Product},
+		})
+	}
+}
+
+// gpuProductLabel returns the node label holding the GPU product.
+func gpuProductLabel(gpu *vllm.GPUConfig) string {
+	if gpu.ProductLabel == "" {
+		return vllm.DefaultGPUProductLabel
+	}
+	return gpu.ProductLabel
+}
+
+// withRequiredNodeSelector returns a copy of the given affinity that also
+// requires the given node selector requirement. The requirement is added to
+// every term, which are ORed, so it holds whichever term matches.
+func withRequiredNodeSelector(affinity *corev1.Affinity, req corev1.NodeSelectorRequirement) *corev1.Affinity {
+	if affinity == nil {
+		affinity = &corev1.Affinity{}
+	} else {
+		affinity = affinity.DeepCopy()
+	}
+	if affinity.NodeAffinity == nil {
+		affinity.NodeAffinity = &corev1.NodeAffinity{}
+	}
+	required := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	if required == nil || len(required.NodeSelectorTerms) == 0 {
+		required = &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{{}}}
+	}
+	for i := range required.NodeSelectorTerms {
+		term := &required.NodeSelectorTerms[i]
+		term.MatchExpressions = append(term.MatchExpressions, req)
+	}
+	affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = required
+	return affinity
+}
+
+// hasEnvVar reports whether the given environment sets the variable with the given name.
+func hasEnvVar(env []corev1.EnvVar, name string) bool {
+	for _, e := range env {
+		if e.Name == name {
+			return true
+		}
+	}
+	return false
+}
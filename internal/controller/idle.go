@@ -0,0 +1,363 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vllm "github.com/revving-ai/vLLM-k8s-operator/api/v1alpha1"
+	"github.com/revving-ai/vLLM-k8s-operator/internal/activator"
+)
+
+const (
+	// activatorLabel selects the activator pods of a vllmDeployment. They do
+	// not carry the app label so that they are not mistaken for vLLM pods.
+	activatorLabel = "vllmoperator.org/activator"
+	// activatorPort receives the requests held by the activator. It is fixed
+	// and unprivileged, so that the activator binds it as non-root whatever
+	// the vLLM port; the Service targets it while scaled to zero.
+	activatorPort = 8080
+	// activatorMetricsPort serves the metrics and the health of the activator.
+	activatorMetricsPort = 9090
+	// defaultActivationTimeout is how long the activator holds a request when
+	// spec.idle.activationTimeout is not set.
+	defaultActivationTimeout = 15 * time.Minute
+	// idleSyncPeriod is how often the request counters of the vLLM pods are
+	// scraped while the model is served.
+	idleSyncPeriod = 30 * time.Second
+	// activatorSyncPeriod is how often the held requests are read while the
+	// workload is scaled to zero, which bounds the cold start latency.
+	activatorSyncPeriod = 2 * time.Second
+
+	metricRequestSuccessTotal = "vllm:request_success_total"
+)
+
+// isScaledToZero reports whether spec.idle currently keeps the workload at
+// zero replicas.
+func isScaledToZero(v *vllm.VllmDeployment) bool {
+	return isRoutedToActivator(v) && v.Status.Idle.WakeTime == nil
+}
+
+// isRoutedToActivator reports whether the Service sends the requests to the
+// activator: from the scale to zero until a replica is ready again.
+func isRoutedToActivator(v *vllm.VllmDeployment) bool {
+	return v.Spec.Idle != nil && v.Status.Idle != nil && v.Status.Idle.ScaledToZeroTime != nil
+}
+
+// activationTimeout returns how long the activator holds a request.
+func activationTimeout(v *vllm.VllmDeploymentSpec) time.Duration {
+	return durationOrDefault(v.Idle.ActivationTimeout, defaultActivationTimeout)
+}
+
+// activatorName returns the name of the activator Deployment.
+func activatorName(v *vllm.VllmDeployment) string {
+	return fmt.Sprintf("%s-activator", v.Name)
+}
+
+// backendServiceName returns the name of the Service the activator forwards
+// the requests to, which always selects the vLLM pods.
+func backendServiceName(v *vllm.VllmDeployment) string {
+	return fmt.Sprintf("%s-backend", v.Name)
+}
+
+// activatorSelector returns the labels of the activator pods.
+func activatorSelector(v *vllm.VllmDeployment) map[string]string {
+	return map[string]string{activatorLabel: v.Name}
+}
+
+// constructActivatorDeployment constructs the Deployment of the activator
+// receiving the requests of the given vllmDeployment while it is scaled to zero.
+func constructActivatorDeployment(v *vllm.VllmDeployment, image string) *appsv1.Deployment {
+	backend := fmt.Sprintf("http://%s.%s.svc:%d", backendServiceName(v), v.Namespace, vllmPort(v))
+
+	return &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      activatorName(v),
+			Namespace: v.Namespace,
+			Labels:    activatorSelector(v),
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: ptr.To[int32](1),
+			Selector: &metav1.LabelSelector{MatchLabels: activatorSelector(v)},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: activatorSelector(v)},
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{
+						Name:    "activator",
+						Image:   image,
+						Command: []string{"/activator"},
+						Args: []string{
+							fmt.Sprintf("--port=%d", activatorPort),
+							fmt.Sprintf("--metrics-port=%d", activatorMetricsPort),
+							"--backend=" + backend,
+							"--timeout=" + activationTimeout(&v.Spec).String(),
+						},
+						Ports: []corev1.ContainerPort{
+							{Name: "http", ContainerPort: activatorPort, Protocol: corev1.ProtocolTCP},
+							{Name: "metrics", ContainerPort: activatorMetricsPort, Protocol: corev1.ProtocolTCP},
+						},
+						ReadinessProbe: &corev1.Probe{
+							ProbeHandler: corev1.ProbeHandler{
+								HTTPGet: &corev1.HTTPGetAction{
+									Path: "/healthz",
+									Port: intstr.FromString("metrics"),
+								},
+							},
+						},
+						SecurityContext: &corev1.SecurityContext{
+							AllowPrivilegeEscalation: ptr.To(false),
+							RunAsNonRoot:             ptr.To(true),
+							Capabilities:             &corev1.Capabilities{Drop: []corev1.Capability{"ALL"}},
+						},
+					}},
+				},
+			},
+		},
+	}
+}
+
+// constructBackendService constructs the ClusterIP Service the activator
+// forwards the requests to.
+func constructBackendService(v *vllm.VllmDeployment) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      backendServiceName(v),
+			Namespace: v.Namespace,
+			Labels:    map[string]string{"app": v.Name},
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: serviceSelector(v),
+			Ports: []corev1.ServicePort{{
+				Name:       "http",
+				Port:       vllmPort(v),
+				TargetPort: intstr.FromInt32(vllmPort(v)),
+				Protocol:   corev1.ProtocolTCP,
+			}},
+		},
+	}
+}
+
+// reconcileActivator creates or updates the activator and its backend Service
+// when spec.idle is set, and deletes them otherwise.
+func (r *VllmDeploymentReconciler) reconcileActivator(ctx context.Context, v *vllm.VllmDeployment) error {
+	if v.Spec.Idle == nil {
+		_, err := r.deleteOwnedObjects(ctx, v,
+			&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: activatorName(v), Namespace: v.Namespace}},
+			&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: backendServiceName(v), Namespace: v.Namespace}})
+		return err
+	}
+
+	image := v.Spec.Idle.ActivatorImage
+	if image == "" {
+		image = r.ActivatorImage
+	}
+	if image == "" {
+		return errors.New("spec.idle requires an activator image: set spec.idle.activatorImage or the --activator-image flag of the operator")
+	}
+
+	desired := []client.Object{constructActivatorDeployment(v, image), constructBackendService(v)}
+	_, err := r.applyObjects(ctx, v, desired, nil)
+	return err
+}
+
+// idleState is what the operator remembers of the requests served by a
+// vllmDeployment with spec.idle.
+type idleState struct {
+	lastCheck   time.Time
+	requests    float64
+	lastRequest time.Time
+}
+
+// idleDue reports whether the request counters of the given vllmDeployment
+// are to be scraped again, and records the check if so.
+func (a *metricsAutoscaler) idleDue(key types.NamespacedName, now time.Time) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	state, ok := a.idle[key]
+	if !ok {
+		state = &idleState{}
+		a.idle[key] = state
+	}
+	if !state.lastCheck.IsZero() && now.Sub(state.lastCheck) < idleSyncPeriod {
+		return false
+	}
+	state.lastCheck = now
+	return true
+}
+
+// observeRequests records the total of the request counters of the given
+// vllmDeployment and whether requests are in flight, and returns when the
+// last request was seen. The first observation counts as a request, so that
+// the idle time starts when the operator starts watching.
+func (a *metricsAutoscaler) observeRequests(key types.NamespacedName, now time.Time, requests float64, inFlight bool) time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	state, ok := a.idle[key]
+	if !ok {
+		state = &idleState{}
+		a.idle[key] = state
+	}
+	if state.lastRequest.IsZero() || inFlight || requests != state.requests {
+		state.lastRequest = now
+	}
+	state.requests = requests
+	return state.lastRequest
+}
+
+// forgetIdle drops the request history of the given vllmDeployment.
+func (a *metricsAutoscaler) forgetIdle(key types.NamespacedName) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.idle, key)
+}
+
+// reconcileIdle moves the given vllmDeployment between serving, scaled to
+// zero and waking up, and persists the transitions in status.idle right away
+// so that the workload and the Service follow in the same reconciliation:
+//   - while serving, the workload is scaled to zero once the vLLM pods served
+//     no request for spec.idle.after;
+//   - while scaled to zero, a request held by the activator wakes it up;
+//   - while waking up, the first ready replica ends the cold start.
+func (r *VllmDeploymentReconciler) reconcileIdle(ctx context.Context, v *vllm.VllmDeployment) error {
+	key := client.ObjectKeyFromObject(v)
+	if v.Spec.Idle == nil {
+		if r.autoscaler != nil {
+			r.autoscaler.forgetIdle(key)
+		}
+		if v.Status.Idle == nil {
+			return nil
+		}
+		v.Status.Idle = nil
+		return r.Status().Update(ctx, v)
+	}
+	if r.autoscaler == nil {
+		return errors.New("the operator autoscaler is not running")
+	}
+	log := log.FromContext(ctx)
+
+	idle := &vllm.IdleStatus{}
+	if v.Status.Idle != nil {
+		idle = v.Status.Idle.DeepCopy()
+	}
+	now := r.autoscaler.now()
+
+	switch {
+	case idle.ScaledToZeroTime == nil:
+		if !r.autoscaler.idleDue(key, now) {
+			return nil
+		}
+		pods, err := r.readyPods(ctx, v.Namespace, client.MatchingLabels{"app": v.Name})
+		if err != nil {
+			return err
+		}
+		samples := r.scrapePods(ctx, pods, vllmPort(v))
+		if len(samples) == 0 {
+			// the model is not served yet, it cannot be idle
+			return nil
+		}
+		var requests, inFlight float64
+		for _, sample := range samples {
+			requests += sample[metricRequestSuccessTotal]
+			inFlight += sample[metricNumRequestsRunning] + sample[metricNumRequestsWaiting]
+		}
+		lastRequest := r.autoscaler.observeRequests(key, now, requests, inFlight > 0)
+		if now.Sub(lastRequest) < v.Spec.Idle.After.Duration {
+			return nil
+		}
+		log.Info("Scaling to zero", "lastRequest", lastRequest)
+		idle.ScaledToZeroTime = &metav1.Time{Time: now}
+		r.recorder.Eventf(v, corev1.EventTypeNormal, vllm.EventReasonScaledToZero,
+			"No request since %s, scaling to zero", lastRequest.UTC().Format(time.RFC3339))
+
+	case idle.WakeTime == nil:
+		pods, err := r.readyPods(ctx, v.Namespace, activatorSelector(v))
+		if err != nil {
+			return err
+		}
+		var pending float64
+		for _, sample := range r.scrapePods(ctx, pods, activatorMetricsPort) {
+			pending += sample[activator.MetricRequestsPending]
+		}
+		if pending == 0 {
+			return nil
+		}
+		log.Info("Waking up", "pendingRequests", pending)
+		idle.WakeTime = &metav1.Time{Time: now}
+		r.recorder.Eventf(v, corev1.EventTypeNormal, vllm.EventReasonWakingUp,
+			"%g requests held by the activator, scaling up", pending)
+
+	default:
+		pods, err := r.readyPods(ctx, v.Namespace, client.MatchingLabels{"app": v.Name})
+		if err != nil {
+			return err
+		}
+		if len(pods) == 0 {
+			return nil
+		}
+		coldStart := now.Sub(idle.WakeTime.Time).Round(time.Second)
+		log.Info("Woken up", "coldStart", coldStart)
+		idle = &vllm.IdleStatus{LastColdStartDuration: &metav1.Duration{Duration: coldStart}}
+		// the request counters start over with the new pods
+		r.autoscaler.forgetIdle(key)
+		r.recorder.Eventf(v, corev1.EventTypeNormal, vllm.EventReasonColdStartComplete,
+			"First replica ready %s after the wake up", coldStart)
+	}
+
+	v.Status.Idle = idle
+	return r.Status().Update(ctx, v)
+}
+
+// setIdleCondition sets the ScaledToZero condition of a vllmDeployment with
+// spec.idle from status.idle, and removes it otherwise.
+func setIdleCondition(status *vllm.VllmDeploymentStatus, v *vllm.VllmDeployment) {
+	if v.Spec.Idle == nil {
+		meta.RemoveStatusCondition(&status.Conditions, vllm.ConditionScaledToZero)
+		return
+	}
+	condition := metav1.Condition{
+		Type:               vllm.ConditionScaledToZero,
+		Status:             metav1.ConditionFalse,
+		Reason:             vllm.ReasonServing,
+		Message:            "Requests are served by the vLLM pods",
+		ObservedGeneration: v.Generation,
+	}
+	switch idle := status.Idle; {
+	case idle == nil || idle.ScaledToZeroTime == nil:
+	case idle.WakeTime == nil:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = vllm.ReasonIdle
+		condition.Message = "Scaled to zero, requests are held by the activator"
+	default:
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = vllm.ReasonWakingUp
+		condition.Message = "Scaling up for the requests held by the activator"
+	}
+	meta.SetStatusCondition(&status.Conditions, condition)
+}
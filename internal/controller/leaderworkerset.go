@@ -0,0 +1,121 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vllm "github.com/revving-ai/vLLM-k8s-operator/api/v1alpha1"
+)
+
+// leaderWorkerSetGVK is the kind of the LeaderWorkerSet workload. The API
+// module is not a dependency of the operator, so the objects are handled as
+// unstructured.
+var leaderWorkerSetGVK = schema.GroupVersionKind{
+	Group:   "leaderworkerset.x-k8s.io",
+	Version: "v1",
+	Kind:    "LeaderWorkerSet",
+}
+
+// leaderWorkerSetName returns the name of the LeaderWorkerSet of the given vllmDeployment.
+func leaderWorkerSetName(v *vllm.VllmDeployment) string {
+	return fmt.Sprintf("%s-lws", v.Name)
+}
+
+// hasLeaderWorkerSetAPI reports whether the LeaderWorkerSet CRD is installed.
+func (r *VllmDeploymentReconciler) hasLeaderWorkerSetAPI() bool {
+	_, err := r.RESTMapper().RESTMapping(leaderWorkerSetGVK.GroupKind(), leaderWorkerSetGVK.Version)
+	return err == nil
+}
+
+// constructLeaderWorkerSet constructs the LeaderWorkerSet running one group of
+// pods per replica of the given vllmDeployment. Without multiNode every group
+// is a single pod running the plain vLLM template.
+func constructLeaderWorkerSet(v *vllm.VllmDeployment) (*unstructured.Unstructured, error) {
+	leaderWorkerTemplate := map[string]interface{}{
+		"size": int64(1),
+	}
+	if isMultiNode(&v.Spec) {
+		leaderTemplate := constructLeaderTemplate(v)
+		workerTemplate := constructWorkerTemplate(v, `LEADER_ADDRESS="${LWS_LEADER_ADDRESS}"`)
+
+		leader, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&leaderTemplate)
+		if err != nil {
+			return nil, err
+		}
+		worker, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&workerTemplate)
+		if err != nil {
+			return nil, err
+		}
+		leaderWorkerTemplate["size"] = int64(v.Spec.MultiNode.Size)
+		// the Ray cluster does not survive the loss of one of its members
+		leaderWorkerTemplate["restartPolicy"] = "RecreateGroupOnPodRestart"
+		leaderWorkerTemplate["leaderTemplate"] = leader
+		leaderWorkerTemplate["workerTemplate"] = worker
+	} else {
+		template := constructPodTemplate(v, podLabels(v))
+		worker, err := runtime.DefaultUnstructuredConverter.ToUnstructured(&template)
+		if err != nil {
+			return nil, err
+		}
+		leaderWorkerTemplate["workerTemplate"] = worker
+	}
+
+	lws := &unstructured.Unstructured{}
+	lws.SetGroupVersionKind(leaderWorkerSetGVK)
+	lws.SetName(leaderWorkerSetName(v))
+	lws.SetNamespace(v.Namespace)
+	lws.SetLabels(podLabels(v))
+	lws.Object["spec"] = map[string]interface{}{
+		"replicas":             int64(desiredReplicas(v)),
+		"leaderWorkerTemplate": leaderWorkerTemplate,
+	}
+	return lws, nil
+}
+
+// leaderWorkerSetWorkload runs the vLLM pods with a LeaderWorkerSet, the
+// default for multi-node groups when its CRD is installed.
+type leaderWorkerSetWorkload struct{}
+
+func (leaderWorkerSetWorkload) desired(v *vllm.VllmDeployment) ([]client.Object, error) {
+	lws, err := constructLeaderWorkerSet(v)
+	if err != nil {
+		return nil, err
+	}
+	return []client.Object{lws}, nil
+}
+
+func (leaderWorkerSetWorkload) setStatus(status *vllm.VllmDeploymentStatus, v *vllm.VllmDeployment, objects []client.Object, _ []corev1.Pod) {
+	lws := objects[0].(*unstructured.Unstructured)
+	ready, _, _ := unstructured.NestedInt64(lws.Object, "status", "readyReplicas")
+	updated, _, _ := unstructured.NestedInt64(lws.Object, "status", "updatedReplicas")
+	desired, found, _ := unstructured.NestedInt64(lws.Object, "spec", "replicas")
+	if !found {
+		desired = 1
+	}
+	setReplicaConditions(status, v.Generation, replicaState{
+		desired: int32(desired),
+		updated: int32(updated),
+		ready:   int32(ready),
+	})
+}
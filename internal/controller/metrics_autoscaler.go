@@ -0,0 +1,448 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/prometheus/common/expfmt"
+	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv1 "k8s.io/api/autoscaling/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vllm "github.com/revving-ai/vLLM-k8s-operator/api/v1alpha1"
+)
+
+const (
+	// defaultAutoscalerSyncPeriod is how often the metrics are scraped when
+	// spec.autoscaling.operator.syncPeriod is not set.
+	defaultAutoscalerSyncPeriod = 15 * time.Second
+	// defaultScaleDownStabilizationWindow matches the default of the HPA.
+	defaultScaleDownStabilizationWindow = 5 * time.Minute
+	defaultScaleUpCooldown              = time.Minute
+	defaultScaleDownCooldown            = 5 * time.Minute
+	// autoscalerTolerance is the relative distance to the target within
+	// which the replicas are left alone, as for the HPA.
+	autoscalerTolerance = 0.1
+	// metricsScrapeTimeout bounds the scrape of one pod.
+	metricsScrapeTimeout = 5 * time.Second
+)
+
+// usesOperatorAutoscaler reports whether the replicas are scaled by the
+// autoscaler built into the operator.
+func usesOperatorAutoscaler(v *vllm.VllmDeploymentSpec) bool {
+	return isAutoscaled(v) && v.Autoscaling.Provider == vllm.AutoscalingProviderOperator
+}
+
+// durationOrDefault returns the given duration, or def when it is not set.
+func durationOrDefault(d *metav1.Duration, def time.Duration) time.Duration {
+	if d == nil {
+		return def
+	}
+	return d.Duration
+}
+
+// operatorAutoscalerConfig returns the settings of the Operator provider with
+// their defaults applied.
+func operatorAutoscalerConfig(v *vllm.VllmDeploymentSpec) (syncPeriod, upWindow, downWindow, upCooldown, downCooldown time.Duration) {
+	c := v.Autoscaling.Operator
+	if c == nil {
+		c = &vllm.OperatorAutoscalerConfig{}
+	}
+	return durationOrDefault(c.SyncPeriod, defaultAutoscalerSyncPeriod),
+		durationOrDefault(c.ScaleUpStabilizationWindow, 0),
+		durationOrDefault(c.ScaleDownStabilizationWindow, defaultScaleDownStabilizationWindow),
+		durationOrDefault(c.ScaleUpCooldown, defaultScaleUpCooldown),
+		durationOrDefault(c.ScaleDownCooldown, defaultScaleDownCooldown)
+}
+
+// metricsScraper reads the metrics of a vLLM or activator pod.
+type metricsScraper interface {
+	scrape(ctx context.Context, pod *corev1.Pod, port int32) (map[string]float64, error)
+}
+
+// httpMetricsScraper scrapes the Prometheus endpoint served on the given port,
+// the API port of vLLM.
+type httpMetricsScraper struct {
+	client *http.Client
+}
+
+func (s httpMetricsScraper) scrape(ctx context.Context, pod *corev1.Pod, port int32) (map[string]float64, error) {
+	url := fmt.Sprintf("http://%s/metrics", net.JoinHostPort(pod.Status.PodIP, strconv.Itoa(int(port))))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return parseMetrics(resp.Body)
+}
+
+// parseMetrics returns the value of every gauge, counter and untyped metric
+// of the given Prometheus text exposition, summed over its label sets.
+func parseMetrics(r io.Reader) (map[string]float64, error) {
+	var parser expfmt.TextParser
+	families, err := parser.TextToMetricFamilies(r)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]float64, len(families))
+	for name, family := range families {
+		for _, m := range family.GetMetric() {
+			switch {
+			case m.Gauge != nil:
+				values[name] += m.Gauge.GetValue()
+			case m.Counter != nil:
+				values[name] += m.Counter.GetValue()
+			case m.Untyped != nil:
+				values[name] += m.Untyped.GetValue()
+			}
+		}
+	}
+	return values, nil
+}
+
+// recommendation is a replica count computed from the metrics at some time.
+type recommendation struct {
+	replicas  int32
+	timestamp time.Time
+}
+
+// autoscalerState is what the autoscaler remembers of a vllmDeployment
+// between two evaluations.
+type autoscalerState struct {
+	lastEvaluation  time.Time
+	recommendations []recommendation
+	// scrapeFailing is set while no ready pod can be scraped.
+	scrapeFailing bool
+}
+
+// metricsAutoscaler is the autoscaler built into the operator. Its state is
+// kept in memory: after a restart the stabilization windows start empty and
+// only the cooldowns, based on status.autoscaler.lastScaleTime, carry over.
+type metricsAutoscaler struct {
+	scraper metricsScraper
+	now     func() time.Time
+
+	mu     sync.Mutex
+	states map[types.NamespacedName]*autoscalerState
+	idle   map[types.NamespacedName]*idleState
+}
+
+// newMetricsAutoscaler returns an autoscaler scraping the pods over HTTP.
+func newMetricsAutoscaler() *metricsAutoscaler {
+	return &metricsAutoscaler{
+		scraper: httpMetricsScraper{client: &http.Client{Timeout: metricsScrapeTimeout}},
+		now:     time.Now,
+		states:  map[types.NamespacedName]*autoscalerState{},
+		idle:    map[types.NamespacedName]*idleState{},
+	}
+}
+
+// due reports whether the sync period elapsed since the last evaluation of
+// the given vllmDeployment, and records a new evaluation if so.
+func (a *metricsAutoscaler) due(key types.NamespacedName, now time.Time, syncPeriod time.Duration) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	state, ok := a.states[key]
+	if !ok {
+		state = &autoscalerState{}
+		a.states[key] = state
+	}
+	if !state.lastEvaluation.IsZero() && now.Sub(state.lastEvaluation) < syncPeriod {
+		return false
+	}
+	state.lastEvaluation = now
+	return true
+}
+
+// stabilize records the given recommendation and returns the replica count
+// to scale to: the lowest recommendation of the scale up window when it is
+// above the current count, the highest of the scale down window when it is
+// below, and the current count otherwise.
+func (a *metricsAutoscaler) stabilize(key types.NamespacedName, rec recommendation, current int32, upWindow, downWindow time.Duration) int32 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	state, ok := a.states[key]
+	if !ok {
+		state = &autoscalerState{}
+		a.states[key] = state
+	}
+
+	keep := max(upWindow, downWindow)
+	recommendations := []recommendation{rec}
+	for _, r := range state.recommendations {
+		if rec.timestamp.Sub(r.timestamp) <= keep {
+			recommendations = append(recommendations, r)
+		}
+	}
+	state.recommendations = recommendations
+
+	upRecommendation, downRecommendation := rec.replicas, rec.replicas
+	for _, r := range recommendations {
+		age := rec.timestamp.Sub(r.timestamp)
+		if age <= upWindow {
+			upRecommendation = min(upRecommendation, r.replicas)
+		}
+		if age <= downWindow {
+			downRecommendation = max(downRecommendation, r.replicas)
+		}
+	}
+
+	switch {
+	case current < upRecommendation:
+		return upRecommendation
+	case current > downRecommendation:
+		return downRecommendation
+	}
+	return current
+}
+
+// observeScrape records whether the ready pods of the given vllmDeployment
+// could be scraped, and reports whether scraping just started failing.
+func (a *metricsAutoscaler) observeScrape(key types.NamespacedName, ok bool) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	state, found := a.states[key]
+	if !found {
+		state = &autoscalerState{}
+		a.states[key] = state
+	}
+	startedFailing := !ok && !state.scrapeFailing
+	state.scrapeFailing = !ok
+	return startedFailing
+}
+
+// forget drops the state of the given vllmDeployment.
+func (a *metricsAutoscaler) forget(key types.NamespacedName) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	delete(a.states, key)
+}
+
+// recommendReplicas returns the replica count keeping every target metric of
+// the given config at its average value over the scraped pods, clamped to
+// the replica limits, and the reason of the recommendation. It reports false
+// when no pod could be scraped.
+func recommendReplicas(v *vllm.VllmDeploymentSpec, current int32, samples []map[string]float64) (int32, string, bool) {
+	if len(samples) == 0 {
+		return 0, "", false
+	}
+	as := v.Autoscaling
+	targets := []struct {
+		target *vllm.MetricTarget
+		name   string
+	}{
+		{as.NumRequestsWaiting, metricNumRequestsWaiting},
+		{as.NumRequestsRunning, metricNumRequestsRunning},
+		{as.GPUCacheUsage, metricGPUCacheUsage},
+	}
+
+	desired := int32(-1)
+	var reason string
+	for _, t := range targets {
+		if t.target == nil {
+			continue
+		}
+		name := t.name
+		if t.target.MetricName != "" {
+			name = t.target.MetricName
+		}
+		var sum float64
+		for _, sample := range samples {
+			sum += sample[name]
+		}
+		average := sum / float64(len(samples))
+		target := t.target.AverageValue.AsApproximateFloat64()
+
+		replicas := current
+		if ratio := average / target; math.Abs(ratio-1) > autoscalerTolerance {
+			replicas = int32(math.Ceil(sum / target))
+		}
+		if replicas > desired {
+			desired = replicas
+			reason = fmt.Sprintf("%s averages %s per replica for a target of %s",
+				name, strconv.FormatFloat(average, 'g', 4, 64), t.target.AverageValue.String())
+		}
+	}
+	if desired < 0 {
+		return 0, "", false
+	}
+	return max(minReplicas(v), min(desired, as.MaxReplicas)), reason, true
+}
+
+// withinCooldown reports whether scaling from current to desired replicas has
+// to wait for the cooldown following the last scaling.
+func withinCooldown(current, desired int32, lastScale *metav1.Time, now time.Time, upCooldown, downCooldown time.Duration) bool {
+	if lastScale == nil {
+		return false
+	}
+	elapsed := now.Sub(lastScale.Time)
+	if desired > current {
+		return elapsed < upCooldown
+	}
+	return elapsed < downCooldown
+}
+
+// workloadReplicas returns the replicas set on the given workload object.
+func workloadReplicas(obj client.Object) int32 {
+	switch obj := obj.(type) {
+	case *appsv1.Deployment:
+		return ptr.Deref(obj.Spec.Replicas, 1)
+	case *appsv1.StatefulSet:
+		return ptr.Deref(obj.Spec.Replicas, 1)
+	case *unstructured.Unstructured:
+		if replicas, found, _ := unstructured.NestedInt64(obj.Object, "spec", "replicas"); found {
+			return int32(replicas)
+		}
+	}
+	return 1
+}
+
+// scaleWorkload writes the given replica count to the scale subresource of
+// the given workload object.
+func (r *VllmDeploymentReconciler) scaleWorkload(ctx context.Context, obj client.Object, replicas int32) error {
+	patch := client.RawPatch(types.MergePatchType, []byte(fmt.Sprintf(`{"spec":{"replicas":%d}}`, replicas)))
+	var scale client.Object = &autoscalingv1.Scale{}
+	if _, ok := obj.(*unstructured.Unstructured); ok {
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(autoscalingv1.SchemeGroupVersion.WithKind("Scale"))
+		scale = u
+	}
+	return r.SubResource("scale").Patch(ctx, obj, patch, client.WithSubResourceBody(scale))
+}
+
+// readyPods returns the ready pods matching the given labels that serve
+// requests, leaving out the workers of multi-node groups and the pods being
+// deleted.
+func (r *VllmDeploymentReconciler) readyPods(ctx context.Context, namespace string, labels client.MatchingLabels) ([]*corev1.Pod, error) {
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(namespace), labels); err != nil {
+		return nil, err
+	}
+	var ready []*corev1.Pod
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if pod.Labels[roleLabel] == roleWorker || !pod.DeletionTimestamp.IsZero() || !isPodReady(pod) {
+			continue
+		}
+		ready = append(ready, pod)
+	}
+	return ready, nil
+}
+
+// scrapePods returns the metrics of the given pods served on the given port,
+// leaving out the pods that could not be scraped.
+func (r *VllmDeploymentReconciler) scrapePods(ctx context.Context, pods []*corev1.Pod, port int32) []map[string]float64 {
+	log := log.FromContext(ctx)
+
+	var samples []map[string]float64
+	for _, pod := range pods {
+		sample, err := r.autoscaler.scraper.scrape(ctx, pod, port)
+		if err != nil {
+			log.Info("Failed to scrape the metrics", "pod", pod.Name, "error", err.Error())
+			continue
+		}
+		samples = append(samples, sample)
+	}
+	return samples
+}
+
+// autoscale runs the Operator provider for the given vllmDeployment once per
+// sync period: it scrapes the ready vLLM pods, computes the stabilized
+// replica count and writes it to the workload, recording an event. It
+// returns the status of the autoscaler, nil without the Operator provider.
+func (r *VllmDeploymentReconciler) autoscale(ctx context.Context, v *vllm.VllmDeployment, kind vllm.WorkloadKind, objects []client.Object) (*vllm.AutoscalerStatus, error) {
+	key := client.ObjectKeyFromObject(v)
+	if !usesOperatorAutoscaler(&v.Spec) {
+		if r.autoscaler != nil {
+			r.autoscaler.forget(key)
+		}
+		return nil, nil
+	}
+	if r.autoscaler == nil {
+		return nil, errors.New("the operator autoscaler is not running")
+	}
+	if _, err := scaleTargetRef(v, kind); err != nil {
+		return nil, err
+	}
+	log := log.FromContext(ctx)
+
+	status := &vllm.AutoscalerStatus{}
+	if v.Status.Autoscaler != nil {
+		status = v.Status.Autoscaler.DeepCopy()
+	}
+	syncPeriod, upWindow, downWindow, upCooldown, downCooldown := operatorAutoscalerConfig(&v.Spec)
+	now := r.autoscaler.now()
+	if !r.autoscaler.due(key, now, syncPeriod) {
+		return status, nil
+	}
+
+	pods, err := r.readyPods(ctx, v.Namespace, client.MatchingLabels{"app": v.Name})
+	if err != nil {
+		return nil, err
+	}
+	samples := r.scrapePods(ctx, pods, vllmPort(v))
+
+	workload := objects[0]
+	current := workloadReplicas(workload)
+	replicas, reason, ok := recommendReplicas(&v.Spec, current, samples)
+	if !ok {
+		// pods still starting are expected not to be scraped, the warning is
+		// only recorded when scraping ready pods starts failing
+		if current > 0 && len(pods) > 0 && r.autoscaler.observeScrape(key, false) {
+			r.recorder.Eventf(v, corev1.EventTypeWarning, vllm.EventReasonMetricsUnavailable,
+				"No ready pod of %s could be scraped, keeping %d replicas", workload.GetName(), current)
+		}
+		return status, nil
+	}
+	r.autoscaler.observeScrape(key, true)
+	desired := r.autoscaler.stabilize(key, recommendation{replicas: replicas, timestamp: now}, current, upWindow, downWindow)
+	status.DesiredReplicas = desired
+	if desired == current || withinCooldown(current, desired, status.LastScaleTime, now, upCooldown, downCooldown) {
+		return status, nil
+	}
+
+	log.Info("Scaling the workload", "name", workload.GetName(), "from", current, "to", desired, "reason", reason)
+	if err := r.scaleWorkload(ctx, workload, desired); err != nil {
+		return nil, err
+	}
+	r.recordScaling(v, workload, current, desired, reason)
+	status.LastScaleTime = &metav1.Time{Time: now}
+	return status, nil
+}
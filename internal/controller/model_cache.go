@@ -0,0 +1,155 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vllm "github.com/revving-ai/vLLM-k8s-operator/api/v1alpha1"
+)
+
+const (
+	// modelCacheVolumeName is the name of the pod volume the weights are downloaded to.
+	modelCacheVolumeName = "model-cache"
+	// modelCacheMountPath is where the model cache is mounted in the downloader and vllm containers.
+	modelCacheMountPath = "/model-cache"
+	// modelCacheDir is the Hugging Face hub cache inside the model cache volume,
+	// filled by the downloader through HF_HOME and passed to vLLM as --download-dir.
+	modelCacheDir = modelCacheMountPath + "/hub"
+	// modelDownloadPollInterval is how often the download progress is checked.
+	modelDownloadPollInterval = 15 * time.Second
+)
+
+// modelCacheClaimName returns the name of the claim holding the model cache.
+func modelCacheClaimName(v *vllm.VllmDeployment) string {
+	if c := v.Spec.Model.Cache; c != nil && c.ExistingClaim != "" {
+		return c.ExistingClaim
+	}
+	return fmt.Sprintf("%s-model-cache", v.Name)
+}
+
+// hasModelCache reports whether the weights are stored in a model cache.
+func hasModelCache(v *vllm.VllmDeploymentSpec) bool {
+	return v.Model != nil && v.Model.Cache != nil
+}
+
+// constructModelCacheClaim constructs the PersistentVolumeClaim provisioned
+// for the model cache of the given vllmDeployment.
+func constructModelCacheClaim(v *vllm.VllmDeployment) *corev1.PersistentVolumeClaim {
+	cache := v.Spec.Model.Cache
+
+	accessModes := cache.AccessModes
+	if len(accessModes) == 0 {
+		accessModes = []corev1.PersistentVolumeAccessMode{corev1.ReadWriteOnce}
+	}
+
+	claim := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      modelCacheClaimName(v),
+			Namespace: v.Namespace,
+			Labels: map[string]string{
+				"app": v.Name,
+			},
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			AccessModes:      accessModes,
+			StorageClassName: cache.StorageClassName,
+		},
+	}
+	if cache.Size != nil {
+		claim.Spec.Resources.Requests = corev1.ResourceList{
+			corev1.ResourceStorage: *cache.Size,
+		}
+	}
+	return claim
+}
+
+// reconcileModelCache applies the model cache claim when the spec asks for
+// one and does not reference an existing claim. The claim is only ever
+// expanded: the rest of its spec is immutable once bound.
+func (r *VllmDeploymentReconciler) reconcileModelCache(ctx context.Context, v *vllm.VllmDeployment) error {
+	if !hasModelCache(&v.Spec) || v.Spec.Model.Cache.ExistingClaim != "" {
+		return nil
+	}
+	return r.apply(ctx, v, constructModelCacheClaim(v), func(existing, desired client.Object) error {
+		// keep the immutable fields as bound, and the size when larger
+		existingClaim, desiredClaim := existing.(*corev1.PersistentVolumeClaim), desired.(*corev1.PersistentVolumeClaim)
+		desiredClaim.Spec.AccessModes = existingClaim.Spec.AccessModes
+		desiredClaim.Spec.StorageClassName = existingClaim.Spec.StorageClassName
+		existingSize := existingClaim.Spec.Resources.Requests.Storage()
+		if desiredClaim.Spec.Resources.Requests.Storage().Cmp(*existingSize) < 0 {
+			desiredClaim.Spec.Resources.Requests = corev1.ResourceList{corev1.ResourceStorage: *existingSize}
+		}
+		return nil
+	})
+}
+
+// setModelDownloadCondition derives the ModelDownloaded condition from the
+// state of the downloader init container of the given pods.
+func setModelDownloadCondition(status *vllm.VllmDeploymentStatus, generation int64, pods []corev1.Pod) {
+	cond := metav1.Condition{
+		Type:               vllm.ConditionModelDownloaded,
+		Status:             metav1.ConditionFalse,
+		Reason:             vllm.ReasonDownloading,
+		Message:            "Waiting for a pod to start the download",
+		ObservedGeneration: generation,
+	}
+
+	var downloaded bool
+	var failed, downloading string
+	for _, pod := range pods {
+		for _, cs := range pod.Status.InitContainerStatuses {
+			if cs.Name != modelDownloaderName {
+				continue
+			}
+			switch {
+			case cs.State.Terminated != nil && cs.State.Terminated.ExitCode == 0:
+				downloaded = true
+			case cs.State.Terminated != nil:
+				failed = fmt.Sprintf("Download failed on pod %s with exit code %d: %s",
+					pod.Name, cs.State.Terminated.ExitCode, cs.State.Terminated.Message)
+			case cs.LastTerminationState.Terminated != nil && cs.LastTerminationState.Terminated.ExitCode != 0:
+				failed = fmt.Sprintf("Download failed on pod %s with exit code %d after %d restarts: %s",
+					pod.Name, cs.LastTerminationState.Terminated.ExitCode, cs.RestartCount, cs.LastTerminationState.Terminated.Message)
+			case cs.State.Running != nil:
+				downloading = fmt.Sprintf("Downloading on pod %s since %s",
+					pod.Name, cs.State.Running.StartedAt.UTC().Format(time.RFC3339))
+			}
+		}
+	}
+
+	switch {
+	case downloaded:
+		cond.Status = metav1.ConditionTrue
+		cond.Reason = vllm.ReasonDownloaded
+		cond.Message = "Model downloaded to the cache"
+	case failed != "":
+		cond.Reason = vllm.ReasonDownloadFailed
+		cond.Message = failed
+	case downloading != "":
+		cond.Message = downloading
+	}
+
+	meta.SetStatusCondition(&status.Conditions, cond)
+}
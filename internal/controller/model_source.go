@@ -0,0 +1,266 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"path"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/utils/ptr"
+
+	vllm "github.com/revving-ai/vLLM-k8s-operator/api/v1alpha1"
+)
+
+const (
+	// modelDownloaderName is the name of the init container fetching the weights.
+	modelDownloaderName = vllm.ModelDownloaderContainerName
+	// modelSourceVolumeName is the name of the pod volume backed by a pvc or hostPath source.
+	modelSourceVolumeName = "model-source"
+	// modelSourceMountPath is where a pvc or hostPath source is mounted in the vllm container.
+	modelSourceMountPath = "/models"
+	// registryConfigVolumeName is the name of the volume holding the OCI registry credentials.
+	registryConfigVolumeName = "registry-config"
+	// registryConfigMountPath is where the OCI registry credentials are mounted in the fetcher.
+	registryConfigMountPath = "/etc/oras"
+
+	defaultS3FetcherImage  = "amazon/aws-cli:2.17.0"
+	defaultOCIFetcherImage = "ghcr.io/oras-project/oras:v1.2.0"
+)
+
+// modelSourceType returns the kind of storage the weights are loaded from.
+func modelSourceType(v *vllm.VllmDeploymentSpec) vllm.ModelSourceType {
+	if v.Model == nil || v.Model.Source == nil || v.Model.Source.Type == "" {
+		return vllm.ModelSourceHuggingFace
+	}
+	return v.Model.Source.Type
+}
+
+// hasModelSourceConfig reports whether the settings of the selected source type are set.
+func hasModelSourceConfig(v *vllm.VllmDeploymentSpec) bool {
+	switch modelSourceType(v) {
+	case vllm.ModelSourceS3:
+		return v.Model.Source.S3 != nil
+	case vllm.ModelSourceOCI:
+		return v.Model.Source.OCI != nil
+	case vllm.ModelSourcePVC:
+		return v.Model.Source.PVC != nil
+	case vllm.ModelSourceHostPath:
+		return v.Model.Source.HostPath != nil
+	}
+	return true
+}
+
+// hasModelDownloader reports whether an init container fetches the weights
+// before vLLM starts.
+func hasModelDownloader(v *vllm.VllmDeploymentSpec) bool {
+	switch modelSourceType(v) {
+	case vllm.ModelSourceS3, vllm.ModelSourceOCI:
+		return true
+	case vllm.ModelSourceHuggingFace:
+		return hasModelCache(v)
+	}
+	return false
+}
+
+// modelPath returns the value of the --model flag: the Hugging Face
+// repository, or the directory the weights are loaded from.
+func modelPath(v *vllm.VllmDeploymentSpec) string {
+	switch modelSourceType(v) {
+	case vllm.ModelSourceS3, vllm.ModelSourceOCI:
+		return fetchedModelDir(v)
+	case vllm.ModelSourcePVC:
+		return path.Join(modelSourceMountPath, v.Model.Source.PVC.Path)
+	case vllm.ModelSourceHostPath:
+		return modelSourceMountPath
+	}
+	return v.Model.Name
+}
+
+// fetchedModelDir returns the directory the s3 and oci fetchers write the
+// weights to inside the model cache volume.
+func fetchedModelDir(v *vllm.VllmDeploymentSpec) string {
+	return path.Join(modelCacheMountPath, "models", v.Model.Name)
+}
+
+// injectModelSource adds the volumes and the init container needed to load
+// the weights from the configured source, and mounts them into the vllm container.
+func injectModelSource(v *vllm.VllmDeployment, podSpec *corev1.PodSpec, vllmContainer *corev1.Container) {
+	source := v.Spec.Model.Source
+
+	switch modelSourceType(&v.Spec) {
+	case vllm.ModelSourcePVC:
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: modelSourceVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+					ClaimName: source.PVC.ClaimName,
+					ReadOnly:  true,
+				},
+			},
+		})
+		mountModelSource(vllmContainer)
+		return
+	case vllm.ModelSourceHostPath:
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: modelSourceVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				HostPath: &corev1.HostPathVolumeSource{
+					Path: source.HostPath.Path,
+					Type: ptr.To(corev1.HostPathDirectory),
+				},
+			},
+		})
+		mountModelSource(vllmContainer)
+		return
+	}
+
+	if !hasModelDownloader(&v.Spec) {
+		return
+	}
+
+	// the downloaded weights survive pod restarts only with a model cache
+	cacheVolume := corev1.Volume{
+		Name:         modelCacheVolumeName,
+		VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+	}
+	if hasModelCache(&v.Spec) {
+		cacheVolume.VolumeSource = corev1.VolumeSource{
+			PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{
+				ClaimName: modelCacheClaimName(v),
+			},
+		}
+	}
+	podSpec.Volumes = append(podSpec.Volumes, cacheVolume)
+
+	downloader := constructModelDownloaderContainer(&v.Spec, vllmContainer)
+	if modelSourceType(&v.Spec) == vllm.ModelSourceOCI && source.OCI.PullSecretRef != nil {
+		podSpec.Volumes = append(podSpec.Volumes, corev1.Volume{
+			Name: registryConfigVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: source.OCI.PullSecretRef.Name,
+					Items: []corev1.KeyToPath{{
+						Key:  corev1.DockerConfigJsonKey,
+						Path: "config.json",
+					}},
+				},
+			},
+		})
+	}
+	podSpec.InitContainers = append([]corev1.Container{downloader}, podSpec.InitContainers...)
+
+	vllmContainer.VolumeMounts = append(vllmContainer.VolumeMounts, corev1.VolumeMount{
+		Name:      modelCacheVolumeName,
+		MountPath: modelCacheMountPath,
+		ReadOnly:  true,
+	})
+	if modelSourceType(&v.Spec) == vllm.ModelSourceHuggingFace {
+		// the weights are already downloaded, vLLM must not try to write to the cache
+		vllmContainer.Env = append(vllmContainer.Env,
+			corev1.EnvVar{Name: "HF_HOME", Value: modelCacheMountPath},
+			corev1.EnvVar{Name: "HF_HUB_OFFLINE", Value: "1"},
+		)
+	}
+}
+
+// mountModelSource mounts the pvc or hostPath source read-only into the vllm container.
+func mountModelSource(vllmContainer *corev1.Container) {
+	vllmContainer.VolumeMounts = append(vllmContainer.VolumeMounts, corev1.VolumeMount{
+		Name:      modelSourceVolumeName,
+		MountPath: modelSourceMountPath,
+		ReadOnly:  true,
+	})
+}
+
+// constructModelDownloaderContainer constructs the init container that
+// fetches the weights into the model cache volume. Weights already in the
+// cache are not downloaded again.
+func constructModelDownloaderContainer(v *vllm.VllmDeploymentSpec, vllmContainer *corev1.Container) corev1.Container {
+	container := corev1.Container{
+		Name:            modelDownloaderName,
+		ImagePullPolicy: vllmContainer.ImagePullPolicy,
+		VolumeMounts: []corev1.VolumeMount{{
+			Name:      modelCacheVolumeName,
+			MountPath: modelCacheMountPath,
+		}},
+	}
+
+	switch modelSourceType(v) {
+	case vllm.ModelSourceS3:
+		s3 := v.Model.Source.S3
+		container.Image = s3.FetcherImage
+		if container.Image == "" {
+			container.Image = defaultS3FetcherImage
+		}
+		container.ImagePullPolicy = ""
+		container.Command = []string{"aws", "s3", "sync", s3.URI, fetchedModelDir(v)}
+		if s3.Endpoint != "" {
+			container.Command = append(container.Command, "--endpoint-url", s3.Endpoint)
+		}
+		if s3.Region != "" {
+			container.Env = append(container.Env, corev1.EnvVar{Name: "AWS_REGION", Value: s3.Region})
+		}
+		if s3.CredentialsSecretRef != nil {
+			for _, key := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY"} {
+				container.Env = append(container.Env, corev1.EnvVar{
+					Name: key,
+					ValueFrom: &corev1.EnvVarSource{
+						SecretKeyRef: &corev1.SecretKeySelector{
+							LocalObjectReference: *s3.CredentialsSecretRef,
+							Key:                  key,
+						},
+					},
+				})
+			}
+		}
+	case vllm.ModelSourceOCI:
+		oci := v.Model.Source.OCI
+		container.Image = oci.FetcherImage
+		if container.Image == "" {
+			container.Image = defaultOCIFetcherImage
+		}
+		container.ImagePullPolicy = ""
+		container.Command = []string{"oras", "pull", oci.Reference, "--output", fetchedModelDir(v)}
+		if oci.PullSecretRef != nil {
+			container.Command = append(container.Command, "--registry-config", registryConfigMountPath+"/config.json")
+			container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+				Name:      registryConfigVolumeName,
+				MountPath: registryConfigMountPath,
+				ReadOnly:  true,
+			})
+		}
+	default:
+		container.Image = vllmContainer.Image
+		if v.Model.Cache.DownloaderImage != "" {
+			container.Image = v.Model.Cache.DownloaderImage
+		}
+		container.Command = []string{"huggingface-cli", "download", v.Model.Name}
+		// vLLM runs offline, so it only finds the revision it is pinned to
+		// if that revision was downloaded
+		if v.VLLMConfig.Revision != "" {
+			container.Command = append(container.Command, "--revision", v.VLLMConfig.Revision)
+		}
+		container.Env = []corev1.EnvVar{
+			{Name: "HF_HOME", Value: modelCacheMountPath},
+		}
+		if tokenEnv := hfTokenEnvVar(v); tokenEnv != nil {
+			container.Env = append(container.Env, *tokenEnv)
+		}
+	}
+
+	return container
+}
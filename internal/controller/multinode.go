@@ -0,0 +1,176 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+
+	vllm "github.com/revving-ai/vLLM-k8s-operator/api/v1alpha1"
+)
+
+const (
+	// roleLabel tells the leader of a multi-node group from its workers.
+	roleLabel  = "vllmoperator.org/role"
+	roleLeader = "leader"
+	roleWorker = "worker"
+)
+
+// leaderScript starts the Ray head, waits for the workers of the group to
+// join and starts the vLLM server with the arguments passed after $0.
+const leaderScript = `ray start --head --port=%[1]d && \
+until [ "$(python3 -c 'import ray; ray.init(address="auto", logging_level="ERROR"); print(sum(n["Alive"] for n in ray.nodes()))' 2>/dev/null)" -ge %[2]d ]; do
+  echo "Waiting for the workers to join the Ray cluster"; sleep 5
+done && \
+exec python3 -m vllm.entrypoints.openai.api_server "$@"`
+
+// workerScript joins the Ray cluster of the leader of the group.
+const workerScript = `%[1]s
+exec ray start --block --address="${LEADER_ADDRESS}:%[2]d"`
+
+// isMultiNode reports whether each replica is served by a group of pods.
+func isMultiNode(v *vllm.VllmDeploymentSpec) bool {
+	return v.MultiNode != nil
+}
+
+// rayPort returns the port of the Ray head on the leader.
+func rayPort(v *vllm.VllmDeploymentSpec) int32 {
+	if v.MultiNode.RayPort != 0 {
+		return v.MultiNode.RayPort
+	}
+	return vllm.DefaultRayPort
+}
+
+// pipelineParallelSize returns the value of --pipeline-parallel-size: the one
+// set in the vLLM config, or one stage per pod of a multi-node group.
+func pipelineParallelSize(v *vllm.VllmDeploymentSpec) int {
+	if v.VLLMConfig.PipelineParallelSize != 0 {
+		return v.VLLMConfig.PipelineParallelSize
+	}
+	if isMultiNode(v) {
+		return int(v.MultiNode.Size)
+	}
+	return 0
+}
+
+// leaderName returns the name of the leader StatefulSet and of the headless
+// Service giving its pods a stable address.
+func leaderName(v *vllm.VllmDeployment) string {
+	return fmt.Sprintf("%s-leader", v.Name)
+}
+
+// workerName returns the name of the worker StatefulSet.
+func workerName(v *vllm.VllmDeployment) string {
+	return fmt.Sprintf("%s-worker", v.Name)
+}
+
+// withRole returns a copy of the given labels with the role of the pod in its group.
+func withRole(labels map[string]string, role string) map[string]string {
+	roleLabels := make(map[string]string, len(labels)+1)
+	for k, val := range labels {
+		roleLabels[k] = val
+	}
+	roleLabels[roleLabel] = role
+	return roleLabels
+}
+
+// constructLeaderTemplate constructs the template of the group leaders, which
+// run the Ray head and the vLLM server.
+func constructLeaderTemplate(v *vllm.VllmDeployment) corev1.PodTemplateSpec {
+	template := constructPodTemplate(v, withRole(podLabels(v), roleLeader))
+
+	container := findContainer(template.Spec.Containers, getVllmContainer(&v.Spec).Name)
+	// the arguments of the server follow "vllm", which bash takes as $0
+	container.Command = []string{"/bin/bash", "-c",
+		fmt.Sprintf(leaderScript, rayPort(&v.Spec), v.Spec.MultiNode.Size), "vllm"}
+	container.Ports = append(container.Ports, corev1.ContainerPort{
+		Name:          "ray",
+		ContainerPort: rayPort(&v.Spec),
+		Protocol:      corev1.ProtocolTCP,
+	})
+
+	return template
+}
+
+// constructWorkerTemplate constructs the template of the group workers, which
+// join the Ray cluster of their leader found by the given shell snippet
+// setting LEADER_ADDRESS.
+func constructWorkerTemplate(v *vllm.VllmDeployment, leaderAddress string) corev1.PodTemplateSpec {
+	template := constructPodTemplate(v, withRole(podLabels(v), roleWorker))
+
+	container := findContainer(template.Spec.Containers, getVllmContainer(&v.Spec).Name)
+	container.Command = []string{"/bin/bash", "-c", fmt.Sprintf(workerScript, leaderAddress, rayPort(&v.Spec))}
+	container.Args = nil
+	// workers do not serve the API, their health is the one of the Ray process
+	container.Ports = nil
+	container.StartupProbe = nil
+	container.ReadinessProbe = nil
+	container.LivenessProbe = nil
+
+	return template
+}
+
+// readyGroups returns the number of groups whose leader and workers are all
+// ready, matching the pods of the StatefulSet pair by their ordinal.
+func readyGroups(v *vllm.VllmDeployment, pods []corev1.Pod) int32 {
+	workersPerGroup := int(v.Spec.MultiNode.Size - 1)
+	leaders := map[int]bool{}
+	readyWorkers := map[int]int{}
+	for _, pod := range pods {
+		ordinal, ok := podOrdinal(pod.Name)
+		if !ok || !isPodReady(&pod) {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(pod.Name, leaderName(v)+"-"):
+			leaders[ordinal] = true
+		case strings.HasPrefix(pod.Name, workerName(v)+"-"):
+			readyWorkers[ordinal/workersPerGroup]++
+		}
+	}
+
+	var ready int32
+	for group := range leaders {
+		if readyWorkers[group] >= workersPerGroup {
+			ready++
+		}
+	}
+	return ready
+}
+
+// podOrdinal returns the ordinal of a pod created by a StatefulSet.
+func podOrdinal(name string) (int, bool) {
+	i := strings.LastIndex(name, "-")
+	if i < 0 {
+		return 0, false
+	}
+	ordinal, err := strconv.Atoi(name[i+1:])
+	return ordinal, err == nil
+}
+
+// isPodReady reports whether the Ready condition of the given pod is True.
+func isPodReady(pod *corev1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == corev1.PodReady {
+			return c.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
@@ -0,0 +1,120 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vllm "github.com/revving-ai/vLLM-k8s-operator/api/v1alpha1"
+)
+
+// pausedAnnotation pauses the reconciliation of a vllmDeployment when set to
+// "true", like spec.paused, without editing its spec.
+const pausedAnnotation = "vllmoperator.org/paused"
+
+// pausedReason returns the reason of the Paused condition when the
+// reconciliation of the given vllmDeployment is paused, and an empty string
+// otherwise.
+func pausedReason(v *vllm.VllmDeployment) string {
+	switch {
+	case v.Spec.Paused:
+		return vllm.ReasonPausedBySpec
+	case v.Annotations[pausedAnnotation] == "true":
+		return vllm.ReasonPausedByAnnotation
+	}
+	return ""
+}
+
+// reconcilePaused updates the status of the given paused vllmDeployment from
+// its objects, leaving them as they are so that they can be edited by hand.
+func (r *VllmDeploymentReconciler) reconcilePaused(ctx context.Context, v *vllm.VllmDeployment) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.Info("Reconciliation paused, only updating the status", "reason", pausedReason(v))
+
+	w, err := r.workloadFor(r.workloadKind(v))
+	if err != nil {
+		log.Error(err, "Unsupported workload kind")
+		return ctrl.Result{}, r.reportReconcileError(ctx, v, err)
+	}
+	objects, err := r.readWorkload(ctx, v, w)
+	if err != nil {
+		log.Error(err, "Failed to read the workload")
+		return ctrl.Result{}, r.reportReconcileError(ctx, v, err)
+	}
+	if err := r.updateStatus(ctx, v, w, objects, v.Status.Autoscaler); err != nil {
+		log.Error(err, "Failed to update VllmDeployment status")
+		return ctrl.Result{}, err
+	}
+	return ctrl.Result{}, nil
+}
+
+// readWorkload returns the objects of the given workload as read from the
+// cluster, without creating or updating them. Missing objects are returned
+// empty.
+func (r *VllmDeploymentReconciler) readWorkload(ctx context.Context, v *vllm.VllmDeployment, w workload) ([]client.Object, error) {
+	desired, err := w.desired(v)
+	if err != nil {
+		return nil, err
+	}
+	objects := make([]client.Object, 0, len(desired))
+	for _, obj := range desired {
+		gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+		if err != nil {
+			return nil, err
+		}
+		existing := newObjectOfKind(obj, gvk)
+		if err := r.Get(ctx, client.ObjectKeyFromObject(obj), existing); client.IgnoreNotFound(err) != nil {
+			return nil, err
+		}
+		objects = append(objects, existing)
+	}
+	return objects, nil
+}
+
+// setPausedCondition reports in the Paused condition whether the
+// reconciliation of the given vllmDeployment is paused. The condition is left
+// out until it is paused for the first time.
+func setPausedCondition(status *vllm.VllmDeploymentStatus, v *vllm.VllmDeployment) {
+	condition := metav1.Condition{
+		Type:               vllm.ConditionPaused,
+		Status:             metav1.ConditionTrue,
+		Reason:             pausedReason(v),
+		ObservedGeneration: v.Generation,
+	}
+	switch condition.Reason {
+	case vllm.ReasonPausedBySpec:
+		condition.Message = "spec.paused is set, the objects of the VllmDeployment are left as they are"
+	case vllm.ReasonPausedByAnnotation:
+		condition.Message = fmt.Sprintf("The %s annotation is set, the objects of the VllmDeployment are left as they are", pausedAnnotation)
+	default:
+		if meta.FindStatusCondition(status.Conditions, vllm.ConditionPaused) == nil {
+			return
+		}
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = vllm.ReasonResumed
+		condition.Message = "The objects of the VllmDeployment are reconciled"
+	}
+	meta.SetStatusCondition(&status.Conditions, condition)
+}
@@ -0,0 +1,117 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"reflect"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/utils/ptr"
+
+	vllm "github.com/revving-ai/vLLM-k8s-operator/api/v1alpha1"
+)
+
+const (
+	// healthPath is the vLLM endpoint answering once the engine serves requests.
+	healthPath = "/health"
+	// startupFailureThreshold allows 30 minutes for the weights to load at the
+	// default period of 10 seconds.
+	startupFailureThreshold = 180
+)
+
+// constructProbes sets the startup, readiness and liveness probes of the vllm
+// container. Probes from spec.probes take precedence over the ones set on the
+// container, which take precedence over the defaults.
+func constructProbes(v *vllm.VllmDeploymentSpec, container *corev1.Container) {
+	handler := corev1.ProbeHandler{
+		HTTPGet: &corev1.HTTPGetAction{
+			Path: healthPath,
+			Port: intstr.FromInt(v.VLLMConfig.Port),
+		},
+	}
+
+	startup := &corev1.Probe{
+		ProbeHandler:     handler,
+		PeriodSeconds:    10,
+		TimeoutSeconds:   5,
+		SuccessThreshold: 1,
+		FailureThreshold: startupFailureThreshold,
+	}
+	readiness := &corev1.Probe{
+		ProbeHandler:     handler,
+		PeriodSeconds:    10,
+		TimeoutSeconds:   5,
+		SuccessThreshold: 1,
+		FailureThreshold: 3,
+	}
+	liveness := &corev1.Probe{
+		ProbeHandler:     handler,
+		PeriodSeconds:    15,
+		TimeoutSeconds:   5,
+		SuccessThreshold: 1,
+		FailureThreshold: 3,
+	}
+
+	var overrides vllm.ProbesConfig
+	if v.Probes != nil {
+		overrides = *v.Probes
+	}
+	container.StartupProbe = mergeProbe(startup, container.StartupProbe, overrides.Startup)
+	container.ReadinessProbe = mergeProbe(readiness, container.ReadinessProbe, overrides.Readiness)
+	container.LivenessProbe = mergeProbe(liveness, container.LivenessProbe, overrides.Liveness)
+}
+
+// mergeProbe returns the default probe overwritten field by field by the
+// non-zero fields of the first of override and containerProbe that is set, so
+// that a probe only setting timings keeps the default handler and the default
+// values of the other timings. A disabled override removes the probe.
+func mergeProbe(defaultProbe, containerProbe *corev1.Probe, override *vllm.ProbeOverride) *corev1.Probe {
+	source := containerProbe
+	if override != nil {
+		if override.Disabled {
+			return nil
+		}
+		source = &override.Probe
+	}
+	probe := defaultProbe.DeepCopy()
+	if source == nil {
+		return probe
+	}
+	if !reflect.DeepEqual(source.ProbeHandler, corev1.ProbeHandler{}) {
+		probe.ProbeHandler = *source.ProbeHandler.DeepCopy()
+	}
+	if source.InitialDelaySeconds != 0 {
+		probe.InitialDelaySeconds = source.InitialDelaySeconds
+	}
+	if source.TimeoutSeconds != 0 {
+		probe.TimeoutSeconds = source.TimeoutSeconds
+	}
+	if source.PeriodSeconds != 0 {
+		probe.PeriodSeconds = source.PeriodSeconds
+	}
+	if source.SuccessThreshold != 0 {
+		probe.SuccessThreshold = source.SuccessThreshold
+	}
+	if source.FailureThreshold != 0 {
+		probe.FailureThreshold = source.FailureThreshold
+	}
+	if source.TerminationGracePeriodSeconds != nil {
+		probe.TerminationGracePeriodSeconds = ptr.To(*source.TerminationGracePeriodSeconds)
+	}
+	return probe
+}
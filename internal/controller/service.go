@@ -0,0 +1,172 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vllm "github.com/revving-ai/vLLM-k8s-operator/api/v1alpha1"
+)
+
+// serviceName returns the name of the Service created for the given vllmDeployment.
+func serviceName(v *vllm.VllmDeployment) string {
+	return fmt.Sprintf("%s-service", v.Name)
+}
+
+// vllmPort returns the port the vLLM server listens on.
+func vllmPort(v *vllm.VllmDeployment) int32 {
+	if v.Spec.VLLMConfig != nil && v.Spec.VLLMConfig.Port != 0 {
+		return int32(v.Spec.VLLMConfig.Port)
+	}
+	return vllm.DefaultVllmPort
+}
+
+// servicePort returns the port exposed by the Service in front of the vLLM pods.
+func servicePort(v *vllm.VllmDeployment) int32 {
+	if v.Spec.Service != nil && v.Spec.Service.Port != 0 {
+		return v.Spec.Service.Port
+	}
+	return vllmPort(v)
+}
+
+// serviceEndpoint returns the in-cluster URL of the vLLM OpenAI server.
+func serviceEndpoint(v *vllm.VllmDeployment) string {
+	return fmt.Sprintf("http://%s.%s.svc:%d", serviceName(v), v.Namespace, servicePort(v))
+}
+
+// serviceSelector returns the selector of the pods serving the vLLM API: all
+// of them, or only the group leaders with spec.multiNode.
+func serviceSelector(v *vllm.VllmDeployment) map[string]string {
+	selector := map[string]string{
+		"app": v.Name,
+	}
+	if isMultiNode(&v.Spec) {
+		selector[roleLabel] = roleLeader
+	}
+	return selector
+}
+
+// constructService constructs the Service that exposes the vLLM port of the
+// pods created for the given vllmDeployment.
+func constructService(v *vllm.VllmDeployment) *corev1.Service {
+	labels := map[string]string{
+		"app": v.Name,
+	}
+	for k, val := range v.Labels {
+		labels[k] = val
+	}
+
+	port := corev1.ServicePort{
+		Name:       "http",
+		Port:       servicePort(v),
+		TargetPort: intstr.FromInt32(vllmPort(v)),
+		Protocol:   corev1.ProtocolTCP,
+	}
+
+	serviceType := corev1.ServiceTypeClusterIP
+	clusterIP := ""
+	var annotations map[string]string
+
+	if sc := v.Spec.Service; sc != nil {
+		switch sc.Type {
+		case vllm.ServiceTypeNodePort:
+			serviceType = corev1.ServiceTypeNodePort
+		case vllm.ServiceTypeLoadBalancer:
+			serviceType = corev1.ServiceTypeLoadBalancer
+		case vllm.ServiceTypeHeadless:
+			clusterIP = corev1.ClusterIPNone
+		}
+		if serviceType != corev1.ServiceTypeClusterIP {
+			port.NodePort = sc.NodePort
+		}
+		annotations = sc.Annotations
+	}
+
+	selector := serviceSelector(v)
+	if isRoutedToActivator(v) {
+		selector = activatorSelector(v)
+		port.TargetPort = intstr.FromInt32(activatorPort)
+	}
+
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        serviceName(v),
+			Namespace:   v.Namespace,
+			Labels:      labels,
+			Annotations: annotations,
+		},
+		Spec: corev1.ServiceSpec{
+			Type:      serviceType,
+			ClusterIP: clusterIP,
+			Selector:  selector,
+			Ports:     []corev1.ServicePort{port},
+		},
+	}
+}
+
+// reconcileService applies the Service owned by the given vllmDeployment.
+func (r *VllmDeploymentReconciler) reconcileService(ctx context.Context, v *vllm.VllmDeployment) error {
+	log := log.FromContext(ctx)
+	desiredService := constructService(v)
+
+	// clusterIP is immutable, so switching to or from a headless Service
+	// requires recreating it. The delete event triggers the next reconcile.
+	var existingService corev1.Service
+	err := r.Get(ctx, client.ObjectKeyFromObject(desiredService), &existingService)
+	if err == nil {
+		existingHeadless := existingService.Spec.ClusterIP == corev1.ClusterIPNone
+		desiredHeadless := desiredService.Spec.ClusterIP == corev1.ClusterIPNone
+		if existingHeadless != desiredHeadless {
+			log.Info("Deleting Service to switch headless mode", "Service.Name", existingService.Name)
+			return client.IgnoreNotFound(r.Delete(ctx, &existingService))
+		}
+	} else if !apierrors.IsNotFound(err) {
+		return err
+	}
+
+	return r.apply(ctx, v, desiredService, func(existing, _ client.Object) error {
+		// keep the node ports allocated by the cluster, so that unpinning a
+		// node port applied before does not reallocate it
+		if desiredService.Spec.Type != corev1.ServiceTypeClusterIP {
+			desiredService.Spec.Ports = mergeServicePorts(existing.(*corev1.Service).Spec.Ports, desiredService.Spec.Ports)
+		}
+		return nil
+	})
+}
+
+// mergeServicePorts returns the desired ports, keeping the node ports that
+// were allocated by the cluster when the desired port does not pin one.
+func mergeServicePorts(existing, desired []corev1.ServicePort) []corev1.ServicePort {
+	merged := make([]corev1.ServicePort, 0, len(desired))
+	for _, port := range desired {
+		for _, e := range existing {
+			if e.Name == port.Name && port.NodePort == 0 {
+				port.NodePort = e.NodePort
+			}
+		}
+		merged = append(merged, port)
+	}
+	return merged
+}
@@ -0,0 +1,188 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"fmt"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vllm "github.com/revving-ai/vLLM-k8s-operator/api/v1alpha1"
+)
+
+// statefulSetName returns the name of the StatefulSet of a single-node vllmDeployment.
+func statefulSetName(v *vllm.VllmDeployment) string {
+	return fmt.Sprintf("%s-statefulset", v.Name)
+}
+
+// statefulSetServiceName returns the name of the headless Service governing
+// the StatefulSet of a single-node vllmDeployment.
+func statefulSetServiceName(v *vllm.VllmDeployment) string {
+	return fmt.Sprintf("%s-headless", v.Name)
+}
+
+// constructStatefulSet constructs the StatefulSet running the pods of a
+// single-node vllmDeployment, which get a stable name and address through the
+// headless Service governing it.
+func constructStatefulSet(v *vllm.VllmDeployment) *appsv1.StatefulSet {
+	labels := podLabels(v)
+	replicas := desiredReplicas(v)
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      statefulSetName(v),
+			Namespace: v.Namespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:    &replicas,
+			ServiceName: statefulSetServiceName(v),
+			// the pods load the model independently, there is no need to start them in order
+			PodManagementPolicy: appsv1.ParallelPodManagement,
+			Selector:            &metav1.LabelSelector{MatchLabels: labels},
+			Template:            constructPodTemplate(v, labels),
+		},
+	}
+}
+
+// constructStatefulSetService constructs the headless Service giving the pods
+// of the StatefulSet of a single-node vllmDeployment their stable DNS name,
+// <pod>.<name>-headless.<namespace>.svc. The requests go through the Service
+// in front of the pods.
+func constructStatefulSetService(v *vllm.VllmDeployment) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      statefulSetServiceName(v),
+			Namespace: v.Namespace,
+			Labels:    podLabels(v),
+		},
+		Spec: corev1.ServiceSpec{
+			ClusterIP: corev1.ClusterIPNone,
+			Selector:  serviceSelector(v),
+			Ports: []corev1.ServicePort{{
+				Name:     "http",
+				Port:     vllmPort(v),
+				Protocol: corev1.ProtocolTCP,
+			}},
+		},
+	}
+}
+
+// constructLeaderService constructs the headless Service giving the leaders of
+// the StatefulSet pair a stable address for their workers. Not ready leaders
+// are published as they only become ready once their workers joined.
+func constructLeaderService(v *vllm.VllmDeployment) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      leaderName(v),
+			Namespace: v.Namespace,
+			Labels:    podLabels(v),
+		},
+		Spec: corev1.ServiceSpec{
+			ClusterIP:                corev1.ClusterIPNone,
+			PublishNotReadyAddresses: true,
+			Selector:                 withRole(map[string]string{"app": v.Name}, roleLeader),
+			Ports: []corev1.ServicePort{{
+				Name:     "ray",
+				Port:     rayPort(&v.Spec),
+				Protocol: corev1.ProtocolTCP,
+			}},
+		},
+	}
+}
+
+// constructLeaderStatefulSet constructs the StatefulSet running one leader per
+// replica of the given vllmDeployment.
+func constructLeaderStatefulSet(v *vllm.VllmDeployment) *appsv1.StatefulSet {
+	template := constructLeaderTemplate(v)
+	replicas := desiredReplicas(v)
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      leaderName(v),
+			Namespace: v.Namespace,
+			Labels:    podLabels(v),
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:            &replicas,
+			ServiceName:         leaderName(v),
+			PodManagementPolicy: appsv1.ParallelPodManagement,
+			Selector:            &metav1.LabelSelector{MatchLabels: template.Labels},
+			Template:            template,
+		},
+	}
+}
+
+// constructWorkerStatefulSet constructs the StatefulSet running the workers of
+// every group. Worker n belongs to the group of leader n / (size - 1).
+func constructWorkerStatefulSet(v *vllm.VllmDeployment) *appsv1.StatefulSet {
+	workersPerGroup := v.Spec.MultiNode.Size - 1
+	leaderAddress := fmt.Sprintf(`ORDINAL="${HOSTNAME##*-}"
+LEADER_ADDRESS="%s-$((ORDINAL / %d)).%s.%s.svc"`, leaderName(v), workersPerGroup, leaderName(v), v.Namespace)
+
+	template := constructWorkerTemplate(v, leaderAddress)
+	replicas := desiredReplicas(v) * workersPerGroup
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      workerName(v),
+			Namespace: v.Namespace,
+			Labels:    podLabels(v),
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas:            &replicas,
+			ServiceName:         leaderName(v),
+			PodManagementPolicy: appsv1.ParallelPodManagement,
+			Selector:            &metav1.LabelSelector{MatchLabels: template.Labels},
+			Template:            template,
+		},
+	}
+}
+
+// statefulSetWorkload runs the vLLM pods with a StatefulSet, or with a leader
+// and a worker StatefulSet behind a headless Service for multi-node groups.
+type statefulSetWorkload struct{}
+
+func (statefulSetWorkload) desired(v *vllm.VllmDeployment) ([]client.Object, error) {
+	if isMultiNode(&v.Spec) {
+		return []client.Object{
+			constructLeaderService(v),
+			constructLeaderStatefulSet(v),
+			constructWorkerStatefulSet(v),
+		}, nil
+	}
+	// the StatefulSet comes first as the object scaled by the autoscaler
+	return []client.Object{constructStatefulSet(v), constructStatefulSetService(v)}, nil
+}
+
+func (statefulSetWorkload) setStatus(status *vllm.VllmDeploymentStatus, v *vllm.VllmDeployment, objects []client.Object, pods []corev1.Pod) {
+	var state replicaState
+	if isMultiNode(&v.Spec) {
+		leader, worker := objects[1].(*appsv1.StatefulSet), objects[2].(*appsv1.StatefulSet)
+		workersPerGroup := v.Spec.MultiNode.Size - 1
+		state.desired = ptr.Deref(leader.Spec.Replicas, 1)
+		state.updated = min(leader.Status.UpdatedReplicas, worker.Status.UpdatedReplicas/workersPerGroup)
+		state.ready = readyGroups(v, pods)
+	} else {
+		sts := objects[0].(*appsv1.StatefulSet)
+		state.desired = ptr.Deref(sts.Spec.Replicas, 1)
+		state.updated = sts.Status.UpdatedReplicas
+		state.ready = sts.Status.ReadyReplicas
+	}
+	setReplicaConditions(status, v.Generation, state)
+}
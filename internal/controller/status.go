@@ -0,0 +1,377 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	vllm "github.com/revving-ai/vLLM-k8s-operator/api/v1alpha1"
+)
+
+// constructStatus computes the status of the given vllmDeployment from the
+// objects of its workload, as read from the cluster, and its pods.
+func constructStatus(v *vllm.VllmDeployment, w workload, objects []client.Object, pods []corev1.Pod) *vllm.VllmDeploymentStatus {
+	status := v.Status.DeepCopy()
+	status.ObservedGeneration = v.Generation
+	status.Endpoint = serviceEndpoint(v)
+	status.WorkloadKind = workloadKindOf(w)
+	status.Selector = labels.SelectorFromSet(serviceSelector(v)).String()
+
+	// the image and model as deployed, which differ from the spec until the
+	// workload is updated, or while paused when it is edited by hand
+	status.Image, status.Model = "", ""
+	if container := deployedVllmContainer(v, objects); container != nil {
+		status.Image = container.Image
+		status.Model = servedModel(container.Args)
+	}
+
+	w.setStatus(status, v, objects, pods)
+	status.Restarts = containerRestarts(pods)
+	setIdleCondition(status, v)
+	if hasModelDownloader(&v.Spec) {
+		setModelDownloadCondition(status, v.Generation, pods)
+	} else {
+		meta.RemoveStatusCondition(&status.Conditions, vllm.ConditionModelDownloaded)
+	}
+	setPausedCondition(status, v)
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               vllm.ConditionReconcileError,
+		Status:             metav1.ConditionFalse,
+		Reason:             vllm.ReasonReconcileSucceeded,
+		ObservedGeneration: v.Generation,
+	})
+	status.Phase = phaseOf(status.Conditions)
+
+	return status
+}
+
+// deployedVllmContainer returns the vllm container of the pod template of the
+// first of the given workload objects running it, or nil when none does yet.
+func deployedVllmContainer(v *vllm.VllmDeployment, objects []client.Object) *corev1.Container {
+	vllmContainer := getVllmContainer(&v.Spec)
+	if vllmContainer == nil {
+		return nil
+	}
+	for _, obj := range objects {
+		template := workloadPodTemplate(obj)
+		if template == nil {
+			continue
+		}
+		if container := findContainer(template.Spec.Containers, vllmContainer.Name); container != nil {
+			return container
+		}
+	}
+	return nil
+}
+
+// workloadPodTemplate returns the pod template of the given workload object,
+// the leader one for a LeaderWorkerSet, or nil when it has none.
+func workloadPodTemplate(obj client.Object) *corev1.PodTemplateSpec {
+	switch obj := obj.(type) {
+	case *appsv1.Deployment:
+		return &obj.Spec.Template
+	case *appsv1.StatefulSet:
+		return &obj.Spec.Template
+	case *unstructured.Unstructured:
+		content, found, _ := unstructured.NestedMap(obj.Object, "spec", "leaderWorkerTemplate", "leaderTemplate")
+		if !found {
+			content, found, _ = unstructured.NestedMap(obj.Object, "spec", "leaderWorkerTemplate", "workerTemplate")
+		}
+		var template corev1.PodTemplateSpec
+		if !found || runtime.DefaultUnstructuredConverter.FromUnstructured(content, &template) != nil {
+			return nil
+		}
+		return &template
+	}
+	return nil
+}
+
+// servedModel returns the model served by a vllm container with the given
+// arguments: the --model one, or its --served-model-name when the weights are
+// read from a local path.
+func servedModel(args []string) string {
+	var model, servedName string
+	for i := 0; i+1 < len(args); i++ {
+		switch args[i] {
+		case "--model":
+			model = args[i+1]
+		case "--served-model-name":
+			if servedName == "" {
+				servedName = args[i+1]
+			}
+		}
+	}
+	if strings.HasPrefix(model, "/") && servedName != "" {
+		return servedName
+	}
+	return model
+}
+
+// workloadKindOf returns the kind of the given workload.
+func workloadKindOf(w workload) vllm.WorkloadKind {
+	switch w.(type) {
+	case statefulSetWorkload:
+		return vllm.WorkloadKindStatefulSet
+	case leaderWorkerSetWorkload:
+		return vllm.WorkloadKindLeaderWorkerSet
+	}
+	return vllm.WorkloadKindDeployment
+}
+
+// setDeploymentConditions derives the Ready, Available, Progressing, Degraded
+// and ModelLoaded conditions from the state of the given Deployment.
+func setDeploymentConditions(status *vllm.VllmDeploymentStatus, generation int64, d *appsv1.Deployment) {
+	var desired int32 = 1
+	if d.Spec.Replicas != nil {
+		desired = *d.Spec.Replicas
+	}
+
+	available := getDeploymentCondition(d, appsv1.DeploymentAvailable)
+	progressing := getDeploymentCondition(d, appsv1.DeploymentProgressing)
+	replicaFailure := getDeploymentCondition(d, appsv1.DeploymentReplicaFailure)
+
+	deadlineExceeded := progressing != nil && progressing.Status == corev1.ConditionFalse &&
+		progressing.Reason == vllm.ReasonProgressDeadlineExceeded
+	rolloutComplete := d.Status.ObservedGeneration >= d.Generation &&
+		d.Status.UpdatedReplicas == desired &&
+		d.Status.Replicas == desired &&
+		d.Status.AvailableReplicas == desired
+
+	ready := metav1.Condition{
+		Type:    vllm.ConditionReady,
+		Status:  metav1.ConditionFalse,
+		Reason:  vllm.ReasonReplicasNotReady,
+		Message: fmt.Sprintf("%d/%d replicas ready", d.Status.ReadyReplicas, desired),
+	}
+	if rolloutComplete && d.Status.ReadyReplicas >= desired {
+		ready.Status = metav1.ConditionTrue
+		ready.Reason = vllm.ReasonAllReplicasReady
+	}
+
+	availableCond := metav1.Condition{
+		Type:   vllm.ConditionAvailable,
+		Status: metav1.ConditionFalse,
+		Reason: vllm.ReasonDeploymentUnavailable,
+	}
+	if available != nil {
+		availableCond.Message = available.Message
+		if available.Status == corev1.ConditionTrue {
+			availableCond.Status = metav1.ConditionTrue
+			availableCond.Reason = vllm.ReasonDeploymentAvailable
+		}
+	}
+
+	progressingCond := metav1.Condition{
+		Type:   vllm.ConditionProgressing,
+		Status: metav1.ConditionFalse,
+		Reason: vllm.ReasonRolloutComplete,
+	}
+	switch {
+	case deadlineExceeded:
+		progressingCond.Reason = vllm.ReasonProgressDeadlineExceeded
+		progressingCond.Message = progressing.Message
+	case !rolloutComplete:
+		progressingCond.Status = metav1.ConditionTrue
+		progressingCond.Reason = vllm.ReasonRolloutInProgress
+		progressingCond.Message = fmt.Sprintf("%d/%d replicas updated", d.Status.UpdatedReplicas, desired)
+	}
+
+	degradedCond := metav1.Condition{
+		Type:   vllm.ConditionDegraded,
+		Status: metav1.ConditionFalse,
+		Reason: vllm.ReasonAsExpected,
+	}
+	switch {
+	case deadlineExceeded:
+		degradedCond.Status = metav1.ConditionTrue
+		degradedCond.Reason = vllm.ReasonProgressDeadlineExceeded
+		degradedCond.Message = progressing.Message
+	case replicaFailure != nil && replicaFailure.Status == corev1.ConditionTrue:
+		degradedCond.Status = metav1.ConditionTrue
+		degradedCond.Reason = vllm.ReasonReplicaFailure
+		degradedCond.Message = replicaFailure.Message
+	}
+
+	modelLoaded := metav1.Condition{
+		Type:   vllm.ConditionModelLoaded,
+		Status: metav1.ConditionFalse,
+		Reason: vllm.ReasonNoReadyReplicas,
+	}
+	if d.Status.ReadyReplicas > 0 {
+		modelLoaded.Status = metav1.ConditionTrue
+		modelLoaded.Reason = vllm.ReasonModelServing
+	}
+
+	for _, c := range []metav1.Condition{ready, availableCond, progressingCond, degradedCond, modelLoaded} {
+		c.ObservedGeneration = generation
+		meta.SetStatusCondition(&status.Conditions, c)
+	}
+}
+
+// getDeploymentCondition returns the condition with the given type from the
+// Deployment status, or nil when it is not present.
+func getDeploymentCondition(d *appsv1.Deployment, t appsv1.DeploymentConditionType) *appsv1.DeploymentCondition {
+	for i := range d.Status.Conditions {
+		if d.Status.Conditions[i].Type == t {
+			return &d.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// replicaState is the number of desired, updated and ready replicas of a
+// workload without conditions of its own. With multiNode a replica is a group
+// of pods.
+type replicaState struct {
+	desired int32
+	updated int32
+	ready   int32
+}
+
+// setReplicaConditions derives the Ready, Available, Progressing, Degraded and
+// ModelLoaded conditions from the given replica state.
+func setReplicaConditions(status *vllm.VllmDeploymentStatus, generation int64, state replicaState) {
+	status.Replicas = state.desired
+	status.ReadyReplicas = state.ready
+	status.UpdatedReplicas = state.updated
+
+	ready := metav1.Condition{
+		Type:    vllm.ConditionReady,
+		Status:  metav1.ConditionFalse,
+		Reason:  vllm.ReasonReplicasNotReady,
+		Message: fmt.Sprintf("%d/%d replicas ready", state.ready, state.desired),
+	}
+	if state.ready >= state.desired && state.updated >= state.desired {
+		ready.Status = metav1.ConditionTrue
+		ready.Reason = vllm.ReasonAllReplicasReady
+	}
+
+	available := metav1.Condition{
+		Type:   vllm.ConditionAvailable,
+		Status: metav1.ConditionFalse,
+		Reason: vllm.ReasonNoReplicasAvailable,
+	}
+	if state.ready > 0 {
+		available.Status = metav1.ConditionTrue
+		available.Reason = vllm.ReasonReplicasAvailable
+	}
+
+	progressing := metav1.Condition{
+		Type:   vllm.ConditionProgressing,
+		Status: metav1.ConditionFalse,
+		Reason: vllm.ReasonRolloutComplete,
+	}
+	if state.updated < state.desired || state.ready < state.desired {
+		progressing.Status = metav1.ConditionTrue
+		progressing.Reason = vllm.ReasonRolloutInProgress
+		progressing.Message = fmt.Sprintf("%d/%d replicas updated", state.updated, state.desired)
+	}
+
+	degraded := metav1.Condition{
+		Type:   vllm.ConditionDegraded,
+		Status: metav1.ConditionFalse,
+		Reason: vllm.ReasonAsExpected,
+	}
+
+	modelLoaded := metav1.Condition{
+		Type:   vllm.ConditionModelLoaded,
+		Status: metav1.ConditionFalse,
+		Reason: vllm.ReasonNoReadyReplicas,
+	}
+	if state.ready > 0 {
+		modelLoaded.Status = metav1.ConditionTrue
+		modelLoaded.Reason = vllm.ReasonModelServing
+	}
+
+	for _, c := range []metav1.Condition{ready, available, progressing, degraded, modelLoaded} {
+		c.ObservedGeneration = generation
+		meta.SetStatusCondition(&status.Conditions, c)
+	}
+}
+
+// phaseOf summarizes the given conditions, the most severe first.
+func phaseOf(conditions []metav1.Condition) vllm.VllmDeploymentPhase {
+	switch {
+	case meta.IsStatusConditionTrue(conditions, vllm.ConditionReconcileError):
+		return vllm.PhaseError
+	case meta.IsStatusConditionTrue(conditions, vllm.ConditionPaused):
+		return vllm.PhasePaused
+	case meta.IsStatusConditionTrue(conditions, vllm.ConditionScaledToZero):
+		return vllm.PhaseScaledToZero
+	case meta.IsStatusConditionTrue(conditions, vllm.ConditionDegraded):
+		return vllm.PhaseDegraded
+	case meta.IsStatusConditionTrue(conditions, vllm.ConditionReady):
+		return vllm.PhaseReady
+	case meta.IsStatusConditionTrue(conditions, vllm.ConditionProgressing),
+		meta.IsStatusConditionFalse(conditions, vllm.ConditionModelDownloaded):
+		return vllm.PhaseProgressing
+	}
+	return vllm.PhasePending
+}
+
+// updateStatus writes the status computed from the given workload objects and
+// autoscaler state to the vllmDeployment when it differs from the current one.
+func (r *VllmDeploymentReconciler) updateStatus(ctx context.Context, v *vllm.VllmDeployment, w workload, objects []client.Object, autoscaler *vllm.AutoscalerStatus) error {
+	var pods corev1.PodList
+	if err := r.List(ctx, &pods, client.InNamespace(v.Namespace), client.MatchingLabels{"app": v.Name}); err != nil {
+		return err
+	}
+	updatedStatus := constructStatus(v, w, objects, pods.Items)
+	updatedStatus.Autoscaler = autoscaler
+
+	r.recordStatusEvents(v, &v.Status, updatedStatus, pods.Items)
+	if reflect.DeepEqual(v.Status, *updatedStatus) {
+		return nil
+	}
+	v.Status = *updatedStatus
+	return r.Status().Update(ctx, v)
+}
+
+// reportReconcileError records the given reconciliation error in the
+// ReconcileError condition and returns it so it can be passed back to the
+// controller for a retry.
+func (r *VllmDeploymentReconciler) reportReconcileError(ctx context.Context, v *vllm.VllmDeployment, err error) error {
+	changed := meta.SetStatusCondition(&v.Status.Conditions, metav1.Condition{
+		Type:               vllm.ConditionReconcileError,
+		Status:             metav1.ConditionTrue,
+		Reason:             vllm.ReasonReconcileFailed,
+		Message:            err.Error(),
+		ObservedGeneration: v.Generation,
+	})
+	v.Status.Phase = vllm.PhaseError
+	if !changed {
+		return err
+	}
+	r.recorder.Event(v, corev1.EventTypeWarning, vllm.EventReasonReconcileFailed, err.Error())
+	if statusErr := r.Status().Update(ctx, v); statusErr != nil {
+		return errors.Join(err, statusErr)
+	}
+	return err
+}
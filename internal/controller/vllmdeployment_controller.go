@@ -18,21 +18,29 @@ package controller
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"reflect"
+	"sort"
+	"strings"
+	"time"
 
 	appsv1 "k8s.io/api/apps/v1"
+	autoscalingv2 "k8s.io/api/autoscaling/v2"
 	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	vllm "github.com/revving-ai/vLLM-k8s-operator/api/v1alpha1"
 )
@@ -44,11 +52,32 @@ type VllmDeploymentReconciler struct {
 	client.Client
 	Scheme   *runtime.Scheme
 	recorder record.EventRecorder
+	// ActivatorImage is the image of the activator of the vllmDeployments
+	// with spec.idle that do not set their own.
+	ActivatorImage string
+	// autoscaler runs the Operator autoscaling provider and tracks the
+	// requests of the vllmDeployments with spec.idle.
+	autoscaler *metricsAutoscaler
+	// crashLoops remembers the crash-looping containers already reported.
+	crashLoops crashLoopTracker
 }
 
 // +kubebuilder:rbac:groups=core.vllmoperator.org,resources=vllmdeployments,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core.vllmoperator.org,resources=vllmdeployments/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=core.vllmoperator.org,resources=vllmdeployments/finalizers,verbs=update
+// +kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=leaderworkerset.x-k8s.io,resources=leaderworkersets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=autoscaling,resources=horizontalpodautoscalers,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=apps,resources=deployments/scale;statefulsets/scale,verbs=get;update;patch
+// +kubebuilder:rbac:groups=leaderworkerset.x-k8s.io,resources=leaderworkersets/scale,verbs=get;update;patch
+// +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
+// +kubebuilder:rbac:groups="",resources=services,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=persistentvolumeclaims,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups="",resources=pods,verbs=get;list;watch
+// ConfigMaps and Secrets are only read: their content with get, their metadata with list and watch.
+// +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -69,100 +98,169 @@ func (r *VllmDeploymentReconciler) Reconcile(ctx context.Context, req ctrl.Reque
 	if err := r.Get(ctx, req.NamespacedName, &vllmDeployment); err != nil {
 		if apierrors.IsNotFound(err) {
 			log.Info("VllmDeployment resource not found. Ignoring since object might be deleted")
-			return ctrl.Result{}, err
+			return ctrl.Result{}, nil
 		}
 		// requeue the request
 		log.Error(err, "Failed to get VllmDeployment")
 		return ctrl.Result{}, err
 	}
-	// TODO: placeholder to handle deletion later
 	if !vllmDeployment.ObjectMeta.DeletionTimestamp.IsZero() {
 		log.Info("VllmDeployment is being deleted")
-		log.Info(fmt.Sprintf("VllmDeployment is being deleted: Name=%s, Namespace=%s", req.NamespacedName.Name, req.NamespacedName.Namespace))
-		return ctrl.Result{}, nil
+		return r.finalize(ctx, &vllmDeployment)
 	}
-	desiredDeployment := constructDeployment(&vllmDeployment)
-
-	jsonOutput, err := json.MarshalIndent(desiredDeployment, "", " ")
-	if err != nil {
-		log.Error(err, "Failed to convert to json")
-	} else {
-		fmt.Println("**************************")
-		fmt.Println(string(jsonOutput))
-	}
-
-	// Set the owner reference
-	if err := ctrl.SetControllerReference(&vllmDeployment, desiredDeployment, r.Scheme); err != nil {
-		log.Error(err, "Failed to set owner reference on Deploynent")
-		return ctrl.Result{}, nil
-	}
-
-	// checking if the deployment already exists
-
-	var existingDeployment appsv1.Deployment
-	err = r.Get(ctx, types.NamespacedName{
-		Name:      desiredDeployment.Name,
-		Namespace: desiredDeployment.Namespace,
-	}, &existingDeployment)
-	if err != nil && apierrors.IsNotFound(err) {
-		// No deployment found, go ahead and create it.
-		log.Info("Creating a new Deployment", "Deployment.Namespace", desiredDeployment.Namespace, "Deployment.Name", desiredDeployment.Name)
-		if err := r.Create(ctx, desiredDeployment); err != nil {
-			log.Error(err, "Failed to create new Deployment", "Deployment.Namespace", desiredDeployment.Namespace, "Deployment.Name", desiredDeployment.Name)
+	if controllerutil.AddFinalizer(&vllmDeployment, cleanupFinalizer) {
+		if err := r.Update(ctx, &vllmDeployment); err != nil {
 			return ctrl.Result{}, err
 		}
-		return ctrl.Result{Requeue: true}, nil
-	} else if err != nil {
-		// Error reading the Deployment - requeue
-		log.Error(err, "Failed to get deployment")
-		return ctrl.Result{}, err
 	}
-	// Existing Deployment found. Check if there is a need to update it
-	// Compare the desired and existing Deployment specs
-
-	if !reflect.DeepEqual(existingDeployment.Spec, desiredDeployment.Spec) {
-		log.Info("Updating existing deployment")
-		// Create a copy of the existing Deployment to avoid modifying the cache
-		updatedDep := existingDeployment.DeepCopy()
-		updatedDep.Spec = desiredDeployment.Spec
-		//Update the deployment
-		if err := r.Update(ctx, updatedDep); err != nil {
-			log.Error(err, "Failed to update the Deployment")
-			return ctrl.Result{}, err
-		}
-		// Deployment updated successfully - requeue for status update
-		return ctrl.Result{Requeue: true}, nil
+	// Invalid specs are rejected by the validating webhook, but can still reach
+	// the controller when webhooks are disabled. Retrying does not fix them.
+	if err := validateSpec(&vllmDeployment.Spec); err != nil {
+		log.Error(err, "Invalid VllmDeployment spec")
+		r.recorder.Event(&vllmDeployment, corev1.EventTypeWarning, vllm.EventReasonInvalidSpec, err.Error())
+		return ctrl.Result{}, reconcile.TerminalError(r.reportReconcileError(ctx, &vllmDeployment, err))
+	}
+	if pausedReason(&vllmDeployment) != "" {
+		return r.reconcilePaused(ctx, &vllmDeployment)
+	}
 
+	if err := r.reconcileActivator(ctx, &vllmDeployment); err != nil {
+		log.Error(err, "Failed to reconcile the activator")
+		return ctrl.Result{}, r.reportReconcileError(ctx, &vllmDeployment, err)
 	}
 
-	// Update the status of VllmDeployment if necessary
-	// Fetch the latest Deployment status
-	if err := r.Get(ctx, types.NamespacedName{Name: existingDeployment.Name, Namespace: existingDeployment.Namespace}, &existingDeployment); err != nil {
-		log.Error(err, "Failed to re-fetch Deployment for status update")
-		return ctrl.Result{}, err
+	if err := r.reconcileIdle(ctx, &vllmDeployment); err != nil {
+		log.Error(err, "Failed to scale to or from zero")
+		return ctrl.Result{}, r.reportReconcileError(ctx, &vllmDeployment, err)
+	}
+
+	if err := r.reconcileService(ctx, &vllmDeployment); err != nil {
+		log.Error(err, "Failed to reconcile Service")
+		return ctrl.Result{}, r.reportReconcileError(ctx, &vllmDeployment, err)
+	}
+
+	if err := r.checkModelCredentials(ctx, &vllmDeployment); err != nil {
+		log.Error(err, "Failed to check model credentials")
+		return ctrl.Result{}, r.reportReconcileError(ctx, &vllmDeployment, err)
+	}
+
+	if err := r.reconcileModelCache(ctx, &vllmDeployment); err != nil {
+		log.Error(err, "Failed to reconcile model cache")
+		return ctrl.Result{}, r.reportReconcileError(ctx, &vllmDeployment, err)
+	}
+
+	kind := r.workloadKind(&vllmDeployment)
+	w, err := r.workloadFor(kind)
+	if err != nil {
+		log.Error(err, "Unsupported workload kind")
+		return ctrl.Result{}, r.reportReconcileError(ctx, &vllmDeployment, err)
+	}
+
+	objects, err := r.reconcileWorkload(ctx, &vllmDeployment, w)
+	if err != nil {
+		log.Error(err, "Failed to reconcile the workload")
+		return ctrl.Result{}, r.reportReconcileError(ctx, &vllmDeployment, err)
+	}
+
+	if err := r.reconcileAutoscaler(ctx, &vllmDeployment, kind); err != nil {
+		log.Error(err, "Failed to reconcile the autoscaler")
+		return ctrl.Result{}, r.reportReconcileError(ctx, &vllmDeployment, err)
+	}
+
+	autoscalerStatus, err := r.autoscale(ctx, &vllmDeployment, kind, objects)
+	if err != nil {
+		log.Error(err, "Failed to autoscale the workload")
+		return ctrl.Result{}, r.reportReconcileError(ctx, &vllmDeployment, err)
 	}
 
 	// Update the VllmDeployment status
-	updatedStatus := vllmDeployment.Status.DeepCopy()
-	//TODO: CHANGE SOON updatedStatus.Replicas = existingDeployment.Status.ReadyReplicas
-
-	if !reflect.DeepEqual(vllmDeployment.Status, *updatedStatus) {
-		// Update status
-		vllmDeployment.Status = *updatedStatus
-		if err := r.Status().Update(ctx, &vllmDeployment); err != nil {
-			log.Error(err, "Failed to update VllmDeployment status")
-			return ctrl.Result{}, err
+	if err := r.updateStatus(ctx, &vllmDeployment, w, objects, autoscalerStatus); err != nil {
+		log.Error(err, "Failed to update VllmDeployment status")
+		return ctrl.Result{}, err
+	}
+
+	return r.requeueWhileWaiting(ctx, &vllmDeployment), nil
+}
+
+// requeueWhileWaiting returns the result of a successful reconciliation,
+// polling again while the model is downloaded or its credentials are missing.
+func (r *VllmDeploymentReconciler) requeueWhileWaiting(ctx context.Context, v *vllm.VllmDeployment) ctrl.Result {
+	log := log.FromContext(ctx)
+
+	// init container progress does not show up in the Deployment status, so
+	// poll the pods until the model cache is filled.
+	if c := meta.FindStatusCondition(v.Status.Conditions, vllm.ConditionModelDownloaded); c != nil && c.Status != metav1.ConditionTrue {
+		log.Info("Waiting for the model download", "reason", c.Reason)
+		return ctrl.Result{RequeueAfter: modelDownloadPollInterval}
+	}
+
+	// the pods cannot start without the token, check again until it exists
+	if meta.IsStatusConditionTrue(v.Status.Conditions, vllm.ConditionCredentialsMissing) {
+		log.Info("Waiting for the model credentials", "secret", v.Spec.Model.TokenSecretRef.Name)
+		return ctrl.Result{RequeueAfter: credentialsPollInterval}
+	}
+
+	// the metrics are scraped on every sync period of the operator
+	// autoscaler, and of spec.idle: the request counters of the vLLM pods
+	// while serving, the requests held by the activator while scaled to zero
+	var syncPeriod time.Duration
+	if usesOperatorAutoscaler(&v.Spec) {
+		syncPeriod, _, _, _, _ = operatorAutoscalerConfig(&v.Spec)
+	}
+	if v.Spec.Idle != nil {
+		idlePeriod := idleSyncPeriod
+		if isRoutedToActivator(v) {
+			idlePeriod = activatorSyncPeriod
+		}
+		if syncPeriod == 0 || idlePeriod < syncPeriod {
+			syncPeriod = idlePeriod
 		}
 	}
+	if syncPeriod > 0 {
+		return ctrl.Result{RequeueAfter: syncPeriod}
+	}
 
 	log.Info("Reconciliation complete")
 	// Reconciliation successful - don't requeue
-	return ctrl.Result{}, nil
+	return ctrl.Result{}
 }
 
 // constructDeployment constructs a Deployment object based on the given vllmDeployment // by mapping the fields from the vllmDeployment spec to the Deployment spec
 func constructDeployment(v *vllm.VllmDeployment) *appsv1.Deployment {
+	labels := podLabels(v)
+	podTemplate := constructPodTemplate(v, labels)
+
+	replicas := desiredReplicas(v)
+
+	//create the deployment spec
+	deploymentSpec := appsv1.DeploymentSpec{
+		Replicas: &replicas,
+		Selector: &metav1.LabelSelector{
+			MatchLabels: labels,
+		},
+		Template: podTemplate,
+	}
+	// Create the deployment object
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      deploymentName(v),
+			Namespace: v.Namespace,
+			Labels:    labels,
+		},
+		Spec: deploymentSpec,
+	}
+
+	return deployment
+
+}
+
+// deploymentName returns the name of the Deployment of the given vllmDeployment.
+func deploymentName(v *vllm.VllmDeployment) string {
+	return fmt.Sprintf("%s-deployment", v.Name)
+}
 
+// podLabels returns the labels of the pods created for the given vllmDeployment.
+func podLabels(v *vllm.VllmDeployment) map[string]string {
 	labels := map[string]string{
 		"app": v.Name,
 	}
@@ -171,34 +269,12 @@ func constructDeployment(v *vllm.VllmDeployment) *appsv1.Deployment {
 			labels[k] = v
 		}
 	}
+	return labels
+}
 
-	envVars := []corev1.EnvVar{}
-	vllmContainer := getVllmContainer(&v.Spec)
-	if vllmContainer != nil {
-		envVars = vllmContainer.Env
-	}
-
-	args := convertVllmConfigToArgs(&v.Spec)
-
-	// prepare container ports
-	containerPorts := []corev1.ContainerPort{}
-	if port := v.Spec.VLLMConfig.Port; port != 0 {
-		containerPorts = append(containerPorts, corev1.ContainerPort{
-			ContainerPort: int32(port),
-			Protocol:      corev1.ProtocolTCP,
-		})
-	}
-
-	// prepare container spec
-	container := corev1.Container{
-		Name:            vllmContainer.Name,
-		Image:           vllmContainer.Image,
-		ImagePullPolicy: corev1.PullPolicy(vllmContainer.ImagePullPolicy),
-		Env:             envVars,
-		Args:            args,
-		Ports:           containerPorts,
-		// TODO: Add remaining
-	}
+// constructPodTemplate constructs the template of the vLLM pods with the given
+// labels, shared by every workload the operator renders.
+func constructPodTemplate(v *vllm.VllmDeployment, labels map[string]string) corev1.PodTemplateSpec {
 	tolerations := []corev1.Toleration{}
 	if t := v.Spec.Tolerations; t != nil {
 		tolerations = t
@@ -210,36 +286,115 @@ func constructDeployment(v *vllm.VllmDeployment) *appsv1.Deployment {
 			Labels: labels,
 		},
 		Spec: corev1.PodSpec{
-			Containers:  []corev1.Container{container},
-			Tolerations: tolerations,
-			// TODO: add the remaining here.
+			InitContainers:     constructInitContainers(&v.Spec),
+			Containers:         constructContainers(&v.Spec),
+			Tolerations:        tolerations,
+			Volumes:            v.Spec.Volumes,
+			NodeSelector:       v.Spec.NodeSelector,
+			Affinity:           v.Spec.Affinity,
+			ImagePullSecrets:   v.Spec.ImagePullSecrets,
+			ServiceAccountName: v.Spec.ServiceAccountName,
+			PriorityClassName:  v.Spec.PriorityClassName,
 		},
 	}
-	var replicas int32 = 1
-	if v.Spec.Replicas != nil && *v.Spec.Replicas != 0 {
-		replicas = *v.Spec.Replicas
+	vllmContainer := findContainer(podTemplate.Spec.Containers, getVllmContainer(&v.Spec).Name)
+	injectModelSource(v, &podTemplate.Spec, vllmContainer)
+	injectGPU(&v.Spec, &podTemplate.Spec, vllmContainer)
+
+	return podTemplate
+}
+
+// constructContainers returns the containers of the pod template in the order
+// of the spec, with the vllm container replaced by the generated one and every
+// other container, e.g. an authentication proxy, kept as is.
+func constructContainers(v *vllm.VllmDeploymentSpec) []corev1.Container {
+	vllmContainer := constructVllmContainer(v)
+	containers := make([]corev1.Container, 0, len(v.Containers))
+	for _, c := range v.Containers {
+		if c.Name == vllmContainer.Name {
+			containers = append(containers, vllmContainer)
+			continue
+		}
+		containers = append(containers, *c.DeepCopy())
 	}
+	return containers
+}
 
-	//create the deployment spec
-	deploymentSpec := appsv1.DeploymentSpec{
-		Replicas: &replicas,
-		Selector: &metav1.LabelSelector{
-			MatchLabels: labels,
-		},
-		Template: podTemplate,
+// constructInitContainers returns the init containers of the pod template.
+// Init containers with restartPolicy Always run as native sidecars next to
+// the vllm container.
+func constructInitContainers(v *vllm.VllmDeploymentSpec) []corev1.Container {
+	if len(v.InitContainers) == 0 {
+		return nil
 	}
-	// Create the deployment object
-	deployment := &appsv1.Deployment{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      fmt.Sprintf("%s-deployment", v.Name),
-			Namespace: v.Namespace,
-			Labels:    labels,
-		},
-		Spec: deploymentSpec,
+	initContainers := make([]corev1.Container, 0, len(v.InitContainers))
+	for _, c := range v.InitContainers {
+		initContainers = append(initContainers, *c.DeepCopy())
 	}
+	return initContainers
+}
 
-	return deployment
+// constructVllmContainer constructs the vLLM container of the pod template.
+// Everything the user set on the container is kept, except the args and the
+// serving port which are generated from the vLLMConfig.
+func constructVllmContainer(v *vllm.VllmDeploymentSpec) corev1.Container {
+	container := getVllmContainer(v).DeepCopy()
+
+	container.Args = convertVllmConfigToArgs(v)
+	if tokenEnv := hfTokenEnvVar(v); tokenEnv != nil {
+		container.Env = append(container.Env, *tokenEnv)
+	}
+
+	// prepare container ports, the serving port always comes first
+	containerPorts := []corev1.ContainerPort{}
+	if port := v.VLLMConfig.Port; port != 0 {
+		containerPorts = append(containerPorts, corev1.ContainerPort{
+			Name:          vllm.ServingPortName,
+			ContainerPort: int32(port),
+			Protocol:      corev1.ProtocolTCP,
+		})
+	}
+	for _, p := range container.Ports {
+		if int(p.ContainerPort) == v.VLLMConfig.Port {
+			continue
+		}
+		containerPorts = append(containerPorts, p)
+	}
+	container.Ports = containerPorts
+	constructProbes(v, container)
 
+	return *container
+}
+
+// findContainer returns the container with the given name, or nil when there is none.
+func findContainer(containers []corev1.Container, name string) *corev1.Container {
+	for i := range containers {
+		if containers[i].Name == name {
+			return &containers[i]
+		}
+	}
+	return nil
+}
+
+// validateSpec returns an error when the spec lacks a field that is needed to
+// construct the workload.
+func validateSpec(v *vllm.VllmDeploymentSpec) error {
+	if v.Model == nil {
+		return errors.New("spec.model must be set")
+	}
+	if v.VLLMConfig == nil {
+		return errors.New("spec.vLLMConfig must be set")
+	}
+	if getVllmContainer(v) == nil {
+		return fmt.Errorf("spec.containers must hold a single container or one named %q", vllm.DefaultVllmContainerName)
+	}
+	if !hasModelSourceConfig(v) {
+		return fmt.Errorf("spec.model.source.%s must be set", modelSourceType(v))
+	}
+	if v.WorkloadKind == vllm.WorkloadKindDeployment && isMultiNode(v) {
+		return fmt.Errorf("spec.workloadKind %s cannot run spec.multiNode groups", v.WorkloadKind)
+	}
+	return nil
 }
 
 // Util function that will fetch vllm container where are there
@@ -252,7 +407,7 @@ func getVllmContainer(v *vllm.VllmDeploymentSpec) *corev1.Container {
 	}
 	// If there are multiple containers, look for the container with the name "vllm"
 	for _, container := range v.Containers {
-		if container.Name == "vllm" {
+		if container.Name == vllm.DefaultVllmContainerName {
 			return &container
 		}
 	}
@@ -267,7 +422,23 @@ func convertVllmConfigToArgs(v *vllm.VllmDeploymentSpec) []string {
 	args := []string{}
 
 	if model.Name != "" {
-		args = append(args, "--model", model.Name)
+		args = append(args, "--model", modelPath(v))
+	}
+	if modelSourceType(v) == vllm.ModelSourceHuggingFace && model.Cache != nil {
+		args = append(args, "--download-dir", modelCacheDir)
+	}
+	if len(vc.ServedModelName) > 0 {
+		args = append(args, "--served-model-name")
+		args = append(args, vc.ServedModelName...)
+	} else if modelSourceType(v) != vllm.ModelSourceHuggingFace {
+		// clients keep addressing the model by its name rather than its local path
+		args = append(args, "--served-model-name", model.Name)
+	}
+	if vc.Revision != "" {
+		args = append(args, "--revision", vc.Revision)
+	}
+	if vc.TrustRemoteCode {
+		args = append(args, "--trust-remote-code")
 	}
 	if vc.GpuMemoryUtilization != "" {
 		args = append(args, "--gpu-memory-utilization", vc.GpuMemoryUtilization)
@@ -288,21 +459,90 @@ func convertVllmConfigToArgs(v *vllm.VllmDeploymentSpec) []string {
 		args = append(args, "--enforce-eager")
 	}
 
+	if tp := tensorParallelSize(v); tp != 0 {
+		args = append(args, "--tensor-parallel-size", fmt.Sprintf("%d", tp))
+	}
+	if pp := pipelineParallelSize(v); pp != 0 {
+		args = append(args, "--pipeline-parallel-size", fmt.Sprintf("%d", pp))
+	}
+	if isMultiNode(v) {
+		args = append(args, "--distributed-executor-backend", "ray")
+	}
+	if vc.Dtype != "" {
+		args = append(args, "--dtype", vc.Dtype)
+	}
+	if vc.Quantization != "" {
+		args = append(args, "--quantization", vc.Quantization)
+	}
+	if vc.KVCacheDtype != "" {
+		args = append(args, "--kv-cache-dtype", vc.KVCacheDtype)
+	}
+	if vc.MaxNumSeqs != 0 {
+		args = append(args, "--max-num-seqs", fmt.Sprintf("%d", vc.MaxNumSeqs))
+	}
+	if vc.MaxNumBatchedTokens != 0 {
+		args = append(args, "--max-num-batched-tokens", fmt.Sprintf("%d", vc.MaxNumBatchedTokens))
+	}
+	if vc.EnablePrefixCaching {
+		args = append(args, "--enable-prefix-caching")
+	}
+	if vc.EnableChunkedPrefill {
+		args = append(args, "--enable-chunked-prefill")
+	}
+	if vc.SwapSpace != "" {
+		args = append(args, "--swap-space", vc.SwapSpace)
+	}
+	if vc.Seed != nil {
+		args = append(args, "--seed", fmt.Sprintf("%d", *vc.Seed))
+	}
+
 	// Add port if specified
 	if vc.Port != 0 {
 		// Adding --port and converting the integer to a string
 		args = append(args, "--port", fmt.Sprintf("%d", vc.Port))
 	}
 
+	// extra arguments go last, after every flag rendered by the operator
+	args = append(args, vc.ExtraArgs...)
+	names := make([]string, 0, len(vc.ExtraArgsMap))
+	for name := range vc.ExtraArgsMap {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return strings.TrimLeft(names[i], "-") < strings.TrimLeft(names[j], "-")
+	})
+	for _, name := range names {
+		args = append(args, "--"+strings.TrimLeft(name, "-"))
+		if value := vc.ExtraArgsMap[name]; value != "" {
+			args = append(args, value)
+		}
+	}
+
 	return args
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *VllmDeploymentReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	r.recorder = mgr.GetEventRecorderFor(controllerName)
-	return ctrl.NewControllerManagedBy(mgr).
+	r.autoscaler = newMetricsAutoscaler()
+	b := ctrl.NewControllerManagedBy(mgr).
 		For(&vllm.VllmDeployment{}).
 		Owns(&appsv1.Deployment{}).
-		Named(controllerName).
-		Complete(r)
+		Owns(&appsv1.StatefulSet{}).
+		Owns(&corev1.Service{}).
+		Owns(&corev1.PersistentVolumeClaim{}).
+		Owns(&autoscalingv2.HorizontalPodAutoscaler{}).
+		// only the metadata of ConfigMaps and Secrets is cached, their content
+		// is read from the API server, see cmd/main.go
+		Watches(&corev1.ConfigMap{}, handler.EnqueueRequestsFromMapFunc(r.vllmDeploymentsReading(vllm.ConfigReferenceConfigMap)),
+			builder.OnlyMetadata).
+		Watches(&corev1.Secret{}, handler.EnqueueRequestsFromMapFunc(r.vllmDeploymentsReading(vllm.ConfigReferenceSecret)),
+			builder.OnlyMetadata)
+	// LeaderWorkerSets are only watched when their CRD is installed at startup
+	if _, err := mgr.GetRESTMapper().RESTMapping(leaderWorkerSetGVK.GroupKind(), leaderWorkerSetGVK.Version); err == nil {
+		lws := &unstructured.Unstructured{}
+		lws.SetGroupVersionKind(leaderWorkerSetGVK)
+		b = b.Owns(lws)
+	}
+	return b.Named(controllerName).Complete(r)
 }
@@ -18,11 +18,22 @@ package controller
 
 import (
 	"context"
+	"strings"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -51,7 +62,19 @@ var _ = Describe("VllmDeployment Controller", func() {
 						Name:      resourceName,
 						Namespace: "default",
 					},
-					// TODO(user): Specify other spec details if needed.
+					Spec: corev1alpha1.VllmDeploymentSpec{
+						Replicas: ptr.To[int32](1),
+						Model: &corev1alpha1.ModelConfig{
+							Name: "keeeeenw/MicroLlama",
+						},
+						VLLMConfig: &corev1alpha1.VLLMConfig{
+							Port: 8072,
+						},
+						Containers: []corev1.Container{{
+							Name:  "vllm",
+							Image: "vllm/vllm-openai:v0.6.2",
+						}},
+					},
 				}
 				Expect(k8sClient.Create(ctx, resource)).To(Succeed())
 			}
@@ -64,13 +87,14 @@ var _ = Describe("VllmDeployment Controller", func() {
 			Expect(err).NotTo(HaveOccurred())
 
 			By("Cleanup the specific resource instance VllmDeployment")
-			Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+			deleteAndFinalize(ctx, resource)
 		})
 		It("should successfully reconcile the resource", func() {
 			By("Reconciling the created resource")
 			controllerReconciler := &VllmDeploymentReconciler{
-				Client: k8sClient,
-				Scheme: k8sClient.Scheme(),
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				recorder: &record.FakeRecorder{},
 			}
 
 			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
@@ -80,5 +104,1519 @@ var _ = Describe("VllmDeployment Controller", func() {
 			// TODO(user): Add more specific assertions depending on your controller's reconciliation logic.
 			// Example: If you expect a certain status condition after reconciliation, verify it here.
 		})
+		It("should create a ClusterIP Service for the vLLM port", func() {
+			controllerReconciler := &VllmDeploymentReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				recorder: &record.FakeRecorder{},
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			service := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{
+				Name:      resourceName + "-service",
+				Namespace: "default",
+			}, service)).To(Succeed())
+			Expect(service.Spec.Type).To(Equal(corev1.ServiceTypeClusterIP))
+			Expect(service.Spec.Selector).To(HaveKeyWithValue("app", resourceName))
+			Expect(service.Spec.Ports).To(HaveLen(1))
+			Expect(service.Spec.Ports[0].Port).To(Equal(int32(8072)))
+			Expect(service.OwnerReferences).To(HaveLen(1))
+		})
+		It("should report the endpoint, image and model in status", func() {
+			controllerReconciler := &VllmDeploymentReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				recorder: &record.FakeRecorder{},
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			resource := &corev1alpha1.VllmDeployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.ObservedGeneration).To(Equal(resource.Generation))
+			Expect(resource.Status.Endpoint).To(Equal("http://test-resource-service.default.svc:8072"))
+			Expect(resource.Status.Image).To(Equal("vllm/vllm-openai:v0.6.2"))
+			Expect(resource.Status.Model).To(Equal("keeeeenw/MicroLlama"))
+		})
+		It("should replace the Deployment when the workload kind changes", func() {
+			resource := &corev1alpha1.VllmDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "workload-kind", Namespace: "default"},
+				Spec: corev1alpha1.VllmDeploymentSpec{
+					Model:      &corev1alpha1.ModelConfig{Name: "keeeeenw/MicroLlama"},
+					VLLMConfig: &corev1alpha1.VLLMConfig{Port: 8072},
+					Containers: []corev1.Container{{Name: "vllm", Image: "vllm/vllm-openai:v0.6.2"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			DeferCleanup(func() {
+				deleteAndFinalize(ctx, resource)
+			})
+			controllerReconciler := &VllmDeploymentReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				recorder: &record.FakeRecorder{},
+			}
+			request := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(resource)}
+
+			_, err := controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			deploymentKey := types.NamespacedName{Name: "workload-kind-deployment", Namespace: "default"}
+			Expect(k8sClient.Get(ctx, deploymentKey, &appsv1.Deployment{})).To(Succeed())
+
+			Expect(k8sClient.Get(ctx, request.NamespacedName, resource)).To(Succeed())
+			resource.Spec.WorkloadKind = corev1alpha1.WorkloadKindStatefulSet
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			_, err = controllerReconciler.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			statefulSet := &appsv1.StatefulSet{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "workload-kind-statefulset", Namespace: "default"}, statefulSet)).To(Succeed())
+			Expect(statefulSet.Spec.ServiceName).To(Equal("workload-kind-headless"))
+			headless := &corev1.Service{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "workload-kind-headless", Namespace: "default"}, headless)).To(Succeed())
+			Expect(headless.Spec.ClusterIP).To(Equal(corev1.ClusterIPNone))
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, deploymentKey, &appsv1.Deployment{}))).To(BeTrue())
+			Expect(k8sClient.Get(ctx, request.NamespacedName, resource)).To(Succeed())
+			Expect(resource.Status.WorkloadKind).To(Equal(corev1alpha1.WorkloadKindStatefulSet))
+		})
+	})
+
+	Context("When computing status conditions", func() {
+		It("should report Ready once the rollout is complete", func() {
+			v := &corev1alpha1.VllmDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "cond", Namespace: "default", Generation: 2},
+			}
+			d := &appsv1.Deployment{
+				Spec: appsv1.DeploymentSpec{Replicas: ptr.To[int32](2)},
+				Status: appsv1.DeploymentStatus{
+					Replicas:          2,
+					UpdatedReplicas:   2,
+					ReadyReplicas:     2,
+					AvailableReplicas: 2,
+					Conditions: []appsv1.DeploymentCondition{{
+						Type:   appsv1.DeploymentAvailable,
+						Status: corev1.ConditionTrue,
+					}},
+				},
+			}
+
+			status := constructStatus(v, deploymentWorkload{}, []client.Object{d}, nil)
+			Expect(meta.IsStatusConditionTrue(status.Conditions, corev1alpha1.ConditionReady)).To(BeTrue())
+			Expect(meta.IsStatusConditionTrue(status.Conditions, corev1alpha1.ConditionAvailable)).To(BeTrue())
+			Expect(meta.IsStatusConditionFalse(status.Conditions, corev1alpha1.ConditionProgressing)).To(BeTrue())
+			Expect(meta.IsStatusConditionTrue(status.Conditions, corev1alpha1.ConditionModelLoaded)).To(BeTrue())
+			Expect(meta.IsStatusConditionFalse(status.Conditions, corev1alpha1.ConditionReconcileError)).To(BeTrue())
+			Expect(status.Phase).To(Equal(corev1alpha1.PhaseReady))
+			Expect(status.Selector).To(Equal("app=cond"))
+		})
+
+		It("should report Degraded when the rollout exceeded its deadline", func() {
+			v := &corev1alpha1.VllmDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "cond", Namespace: "default", Generation: 1},
+			}
+			d := &appsv1.Deployment{
+				Spec: appsv1.DeploymentSpec{Replicas: ptr.To[int32](1)},
+				Status: appsv1.DeploymentStatus{
+					Replicas: 1,
+					Conditions: []appsv1.DeploymentCondition{{
+						Type:   appsv1.DeploymentProgressing,
+						Status: corev1.ConditionFalse,
+						Reason: "ProgressDeadlineExceeded",
+					}},
+				},
+			}
+
+			status := constructStatus(v, deploymentWorkload{}, []client.Object{d}, nil)
+			Expect(meta.IsStatusConditionTrue(status.Conditions, corev1alpha1.ConditionDegraded)).To(BeTrue())
+			Expect(meta.IsStatusConditionFalse(status.Conditions, corev1alpha1.ConditionProgressing)).To(BeTrue())
+			Expect(meta.IsStatusConditionFalse(status.Conditions, corev1alpha1.ConditionReady)).To(BeTrue())
+			Expect(status.Phase).To(Equal(corev1alpha1.PhaseDegraded))
+		})
+
+		It("should report the image and model deployed rather than the ones of the spec", func() {
+			v := &corev1alpha1.VllmDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "cond", Namespace: "default", Generation: 3},
+				Spec: corev1alpha1.VllmDeploymentSpec{
+					Model:      &corev1alpha1.ModelConfig{Name: "keeeeenw/MicroLlama"},
+					VLLMConfig: &corev1alpha1.VLLMConfig{Port: 8072},
+					Containers: []corev1.Container{{Name: "vllm", Image: "vllm/vllm-openai:v0.6.2"}},
+				},
+			}
+			d := &appsv1.Deployment{Spec: appsv1.DeploymentSpec{
+				Replicas: ptr.To[int32](1),
+				Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{{
+					Name:  "vllm",
+					Image: "vllm/vllm-openai:v0.6.1",
+					Args:  []string{"--model", "facebook/opt-125m"},
+				}}}},
+			}}
+
+			status := constructStatus(v, deploymentWorkload{}, []client.Object{d}, nil)
+			Expect(status.Image).To(Equal("vllm/vllm-openai:v0.6.1"))
+			Expect(status.Model).To(Equal("facebook/opt-125m"))
+
+			// not deployed yet
+			status = constructStatus(v, deploymentWorkload{}, []client.Object{&appsv1.Deployment{}}, nil)
+			Expect(status.Image).To(BeEmpty())
+			Expect(status.Model).To(BeEmpty())
+
+			// weights read from a local path are served under their name
+			Expect(servedModel([]string{"--model", "/models/llama", "--served-model-name", "llama"})).To(Equal("llama"))
+		})
+
+		It("should select the leaders of multi-node groups for the scale subresource", func() {
+			v := &corev1alpha1.VllmDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "cond", Namespace: "default", Generation: 1},
+				Spec:       corev1alpha1.VllmDeploymentSpec{MultiNode: &corev1alpha1.MultiNodeConfig{Size: 2}},
+			}
+			sts := &appsv1.StatefulSet{Spec: appsv1.StatefulSetSpec{Replicas: ptr.To[int32](1)}}
+
+			status := constructStatus(v, statefulSetWorkload{}, []client.Object{&corev1.Service{}, sts, sts}, nil)
+			Expect(status.Selector).To(Equal("app=cond,vllmoperator.org/role=leader"))
+			Expect(status.Phase).To(Equal(corev1alpha1.PhaseProgressing))
+		})
+	})
+
+	Context("When constructing the Deployment", func() {
+		It("should keep the user's vllm container and pod settings", func() {
+			v := &corev1alpha1.VllmDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "construct", Namespace: "default"},
+				Spec: corev1alpha1.VllmDeploymentSpec{
+					Model:      &corev1alpha1.ModelConfig{Name: "keeeeenw/MicroLlama"},
+					VLLMConfig: &corev1alpha1.VLLMConfig{Port: 8072},
+					Containers: []corev1.Container{{
+						Name:  "vllm",
+						Image: "vllm/vllm-openai:v0.6.2",
+						Args:  []string{"--ignored"},
+						Resources: corev1.ResourceRequirements{
+							Limits: corev1.ResourceList{"nvidia.com/gpu": resource.MustParse("1")},
+						},
+						VolumeMounts: []corev1.VolumeMount{{Name: "cache", MountPath: "/root/.cache"}},
+					}},
+					Volumes: []corev1.Volume{{
+						Name:         "cache",
+						VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+					}},
+					NodeSelector:       map[string]string{"gpu": "a100"},
+					ServiceAccountName: "vllm",
+					PriorityClassName:  "inference",
+				},
+			}
+
+			podSpec := constructDeployment(v).Spec.Template.Spec
+			Expect(podSpec.Containers).To(HaveLen(1))
+			container := podSpec.Containers[0]
+			Expect(container.Resources.Limits).To(HaveKey(corev1.ResourceName("nvidia.com/gpu")))
+			Expect(container.VolumeMounts).To(Equal(v.Spec.Containers[0].VolumeMounts))
+			Expect(container.Args).NotTo(ContainElement("--ignored"))
+			Expect(container.Args).To(ContainElements("--model", "--port"))
+			Expect(container.Ports).To(ConsistOf(corev1.ContainerPort{Name: "http", ContainerPort: 8072, Protocol: corev1.ProtocolTCP}))
+			Expect(podSpec.Volumes).To(Equal(v.Spec.Volumes))
+			Expect(podSpec.NodeSelector).To(Equal(v.Spec.NodeSelector))
+			Expect(podSpec.ServiceAccountName).To(Equal("vllm"))
+			Expect(podSpec.PriorityClassName).To(Equal("inference"))
+		})
+
+		It("should render sidecars and init containers", func() {
+			v := &corev1alpha1.VllmDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "sidecars", Namespace: "default"},
+				Spec: corev1alpha1.VllmDeploymentSpec{
+					Model:      &corev1alpha1.ModelConfig{Name: "keeeeenw/MicroLlama"},
+					VLLMConfig: &corev1alpha1.VLLMConfig{Port: 8072},
+					Containers: []corev1.Container{
+						{Name: "auth-proxy", Image: "proxy:latest"},
+						{Name: "vllm", Image: "vllm/vllm-openai:v0.6.2"},
+					},
+					InitContainers: []corev1.Container{{
+						Name:          "log-shipper",
+						Image:         "fluent-bit:latest",
+						RestartPolicy: ptr.To(corev1.ContainerRestartPolicyAlways),
+					}},
+				},
+			}
+
+			podSpec := constructDeployment(v).Spec.Template.Spec
+			Expect(podSpec.Containers).To(HaveLen(2))
+			Expect(podSpec.Containers[0]).To(Equal(v.Spec.Containers[0]))
+			Expect(podSpec.Containers[1].Name).To(Equal("vllm"))
+			Expect(podSpec.Containers[1].Args).To(ContainElement("--model"))
+			Expect(podSpec.InitContainers).To(Equal(v.Spec.InitContainers))
+		})
+
+		It("should download the model into the cache and mount it read-only", func() {
+			v := &corev1alpha1.VllmDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "cached", Namespace: "default"},
+				Spec: corev1alpha1.VllmDeploymentSpec{
+					Model: &corev1alpha1.ModelConfig{
+						Name:  "keeeeenw/MicroLlama",
+						Cache: &corev1alpha1.ModelCacheConfig{Size: ptr.To(resource.MustParse("20Gi"))},
+					},
+					VLLMConfig: &corev1alpha1.VLLMConfig{Port: 8072},
+					Containers: []corev1.Container{{Name: "vllm", Image: "vllm/vllm-openai:v0.6.2"}},
+				},
+			}
+
+			podSpec := constructDeployment(v).Spec.Template.Spec
+			Expect(podSpec.InitContainers).To(HaveLen(1))
+			Expect(podSpec.InitContainers[0].Name).To(Equal("model-downloader"))
+			Expect(podSpec.InitContainers[0].Image).To(Equal("vllm/vllm-openai:v0.6.2"))
+			Expect(podSpec.InitContainers[0].Command).To(Equal([]string{"huggingface-cli", "download", "keeeeenw/MicroLlama"}))
+			Expect(podSpec.Volumes).To(ContainElement(HaveField("VolumeSource.PersistentVolumeClaim.ClaimName", "cached-model-cache")))
+			container := podSpec.Containers[0]
+			Expect(container.VolumeMounts).To(ContainElement(corev1.VolumeMount{Name: "model-cache", MountPath: "/model-cache", ReadOnly: true}))
+			Expect(container.Env).To(ContainElement(corev1.EnvVar{Name: "HF_HOME", Value: "/model-cache"}))
+			Expect(container.Args).To(ContainElements("--download-dir", "/model-cache/hub"))
+		})
+
+		It("should download the revision vLLM is pinned to", func() {
+			v := &corev1alpha1.VllmDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "pinned", Namespace: "default"},
+				Spec: corev1alpha1.VllmDeploymentSpec{
+					Model: &corev1alpha1.ModelConfig{
+						Name:  "keeeeenw/MicroLlama",
+						Cache: &corev1alpha1.ModelCacheConfig{Size: ptr.To(resource.MustParse("20Gi"))},
+					},
+					VLLMConfig: &corev1alpha1.VLLMConfig{Port: 8072, Revision: "6403f6a"},
+					Containers: []corev1.Container{{Name: "vllm", Image: "vllm/vllm-openai:v0.6.2"}},
+				},
+			}
+
+			podSpec := constructDeployment(v).Spec.Template.Spec
+			Expect(podSpec.InitContainers[0].Command).To(Equal([]string{
+				"huggingface-cli", "download", "keeeeenw/MicroLlama", "--revision", "6403f6a"}))
+			Expect(podSpec.Containers[0].Args).To(ContainElements("--revision", "6403f6a"))
+		})
+
+		It("should probe the vLLM health endpoint unless overridden", func() {
+			v := &corev1alpha1.VllmDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "probed", Namespace: "default"},
+				Spec: corev1alpha1.VllmDeploymentSpec{
+					Model:      &corev1alpha1.ModelConfig{Name: "keeeeenw/MicroLlama"},
+					VLLMConfig: &corev1alpha1.VLLMConfig{Port: 8072},
+					Containers: []corev1.Container{{
+						Name:          "vllm",
+						Image:         "vllm/vllm-openai:v0.6.2",
+						LivenessProbe: &corev1.Probe{ProbeHandler: corev1.ProbeHandler{Exec: &corev1.ExecAction{Command: []string{"true"}}}},
+					}},
+					Probes: &corev1alpha1.ProbesConfig{
+						Startup: &corev1alpha1.ProbeOverride{Probe: corev1.Probe{PeriodSeconds: 30, FailureThreshold: 120}},
+					},
+				},
+			}
+
+			container := constructDeployment(v).Spec.Template.Spec.Containers[0]
+			Expect(container.ReadinessProbe.HTTPGet.Path).To(Equal("/health"))
+			Expect(container.ReadinessProbe.HTTPGet.Port.IntValue()).To(Equal(8072))
+			Expect(container.StartupProbe.HTTPGet.Path).To(Equal("/health"))
+			Expect(container.StartupProbe.PeriodSeconds).To(Equal(int32(30)))
+			Expect(container.StartupProbe.FailureThreshold).To(Equal(int32(120)))
+			Expect(container.LivenessProbe.Exec.Command).To(Equal([]string{"true"}))
+			Expect(container.LivenessProbe.HTTPGet).To(BeNil())
+		})
+
+		It("should only override the timings set on a probe", func() {
+			v := &corev1alpha1.VllmDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "slow-probed", Namespace: "default"},
+				Spec: corev1alpha1.VllmDeploymentSpec{
+					Model:      &corev1alpha1.ModelConfig{Name: "keeeeenw/MicroLlama"},
+					VLLMConfig: &corev1alpha1.VLLMConfig{Port: 8072},
+					Containers: []corev1.Container{{Name: "vllm", Image: "vllm/vllm-openai:v0.6.2"}},
+					Probes: &corev1alpha1.ProbesConfig{
+						Readiness: &corev1alpha1.ProbeOverride{Probe: corev1.Probe{InitialDelaySeconds: 60}},
+					},
+				},
+			}
+
+			probe := constructDeployment(v).Spec.Template.Spec.Containers[0].ReadinessProbe
+			Expect(probe.HTTPGet.Path).To(Equal("/health"))
+			Expect(probe.HTTPGet.Port.IntValue()).To(Equal(8072))
+			Expect(probe.InitialDelaySeconds).To(Equal(int32(60)))
+			Expect(probe.PeriodSeconds).To(Equal(int32(10)))
+			Expect(probe.TimeoutSeconds).To(Equal(int32(5)))
+			Expect(probe.SuccessThreshold).To(Equal(int32(1)))
+			Expect(probe.FailureThreshold).To(Equal(int32(3)))
+		})
+
+		It("should remove the disabled probes", func() {
+			v := &corev1alpha1.VllmDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "unprobed", Namespace: "default"},
+				Spec: corev1alpha1.VllmDeploymentSpec{
+					Model:      &corev1alpha1.ModelConfig{Name: "keeeeenw/MicroLlama"},
+					VLLMConfig: &corev1alpha1.VLLMConfig{Port: 8072},
+					Containers: []corev1.Container{{
+						Name:          "vllm",
+						Image:         "vllm/vllm-openai:v0.6.2",
+						LivenessProbe: &corev1.Probe{ProbeHandler: corev1.ProbeHandler{Exec: &corev1.ExecAction{Command: []string{"true"}}}},
+					}},
+					Probes: &corev1alpha1.ProbesConfig{
+						Liveness: &corev1alpha1.ProbeOverride{Disabled: true},
+					},
+				},
+			}
+
+			container := constructDeployment(v).Spec.Template.Spec.Containers[0]
+			Expect(container.LivenessProbe).To(BeNil())
+			Expect(container.ReadinessProbe.HTTPGet.Path).To(Equal("/health"))
+			Expect(container.StartupProbe.HTTPGet.Path).To(Equal("/health"))
+		})
+
+		It("should derive the GPU limits, tensor parallelism, shared memory and node affinity", func() {
+			v := &corev1alpha1.VllmDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "sharded", Namespace: "default"},
+				Spec: corev1alpha1.VllmDeploymentSpec{
+					Model:      &corev1alpha1.ModelConfig{Name: "keeeeenw/MicroLlama"},
+					VLLMConfig: &corev1alpha1.VLLMConfig{Port: 8072},
+					Containers: []corev1.Container{{Name: "vllm", Image: "vllm/vllm-openai:v0.6.2"}},
+					Affinity: &corev1.Affinity{NodeAffinity: &corev1.NodeAffinity{
+						RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+							MatchExpressions: []corev1.NodeSelectorRequirement{{Key: "pool", Operator: corev1.NodeSelectorOpIn, Values: []string{"gpu"}}},
+						}}},
+					}},
+					GPU: &corev1alpha1.GPUConfig{
+						Count:            4,
+						ResourceName:     "amd.com/gpu",
+						Product:          "MI300X",
+						ProductLabel:     "amd.com/gpu.product-name",
+						SharedMemorySize: ptr.To(resource.MustParse("16Gi")),
+					},
+				},
+			}
+
+			podSpec := constructDeployment(v).Spec.Template.Spec
+			container := podSpec.Containers[0]
+			Expect(container.Resources.Limits.Name("amd.com/gpu", resource.DecimalSI).Value()).To(Equal(int64(4)))
+			Expect(container.Args).To(ContainElements("--tensor-parallel-size", "4"))
+			Expect(container.Env).To(ContainElement(corev1.EnvVar{Name: "NCCL_DEBUG", Value: "WARN"}))
+			Expect(container.VolumeMounts).To(ContainElement(corev1.VolumeMount{Name: "dshm", MountPath: "/dev/shm"}))
+			Expect(podSpec.Volumes).To(ContainElement(HaveField("VolumeSource.EmptyDir.Medium", corev1.StorageMediumMemory)))
+			expressions := podSpec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchExpressions
+			Expect(expressions).To(HaveLen(2))
+			Expect(expressions[1]).To(Equal(corev1.NodeSelectorRequirement{
+				Key: "amd.com/gpu.product-name", Operator: corev1.NodeSelectorOpIn, Values: []string{"MI300X"},
+			}))
+			Expect(v.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms[0].MatchExpressions).To(HaveLen(1))
+		})
+
+		It("should fetch the weights from S3 and serve them under the model name", func() {
+			v := &corev1alpha1.VllmDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "from-s3", Namespace: "default"},
+				Spec: corev1alpha1.VllmDeploymentSpec{
+					Model: &corev1alpha1.ModelConfig{
+						Name: "llama-3.1-8b",
+						Source: &corev1alpha1.ModelSource{
+							Type: corev1alpha1.ModelSourceS3,
+							S3: &corev1alpha1.S3ModelSource{
+								URI:                  "s3://models/llama-3.1-8b",
+								Endpoint:             "https://storage.googleapis.com",
+								CredentialsSecretRef: &corev1.LocalObjectReference{Name: "s3-credentials"},
+							},
+						},
+					},
+					VLLMConfig: &corev1alpha1.VLLMConfig{Port: 8072},
+					Containers: []corev1.Container{{Name: "vllm", Image: "vllm/vllm-openai:v0.6.2"}},
+				},
+			}
+
+			podSpec := constructDeployment(v).Spec.Template.Spec
+			Expect(podSpec.InitContainers).To(HaveLen(1))
+			fetcher := podSpec.InitContainers[0]
+			Expect(fetcher.Name).To(Equal("model-downloader"))
+			Expect(fetcher.Command).To(Equal([]string{"aws", "s3", "sync", "s3://models/llama-3.1-8b",
+				"/model-cache/models/llama-3.1-8b", "--endpoint-url", "https://storage.googleapis.com"}))
+			Expect(fetcher.Env).To(ContainElement(HaveField("ValueFrom.SecretKeyRef.Key", "AWS_SECRET_ACCESS_KEY")))
+			Expect(podSpec.Volumes).To(ContainElement(HaveField("VolumeSource.EmptyDir", Not(BeNil()))))
+			container := podSpec.Containers[0]
+			Expect(container.Args).To(ContainElements("/model-cache/models/llama-3.1-8b", "--served-model-name", "llama-3.1-8b"))
+			Expect(container.Args).NotTo(ContainElement("--download-dir"))
+		})
+
+		It("should mount a PVC source read-only without downloading", func() {
+			v := &corev1alpha1.VllmDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "from-pvc", Namespace: "default"},
+				Spec: corev1alpha1.VllmDeploymentSpec{
+					Model: &corev1alpha1.ModelConfig{
+						Name: "llama-3.1-8b",
+						Source: &corev1alpha1.ModelSource{
+							Type: corev1alpha1.ModelSourcePVC,
+							PVC:  &corev1alpha1.PVCModelSource{ClaimName: "weights", Path: "llama"},
+						},
+					},
+					VLLMConfig: &corev1alpha1.VLLMConfig{Port: 8072},
+					Containers: []corev1.Container{{Name: "vllm", Image: "vllm/vllm-openai:v0.6.2"}},
+				},
+			}
+
+			podSpec := constructDeployment(v).Spec.Template.Spec
+			Expect(podSpec.InitContainers).To(BeEmpty())
+			Expect(podSpec.Volumes).To(ContainElement(HaveField("VolumeSource.PersistentVolumeClaim.ClaimName", "weights")))
+			container := podSpec.Containers[0]
+			Expect(container.VolumeMounts).To(ContainElement(corev1.VolumeMount{Name: "model-source", MountPath: "/models", ReadOnly: true}))
+			Expect(container.Args).To(ContainElements("--model", "/models/llama"))
+		})
+	})
+
+	Context("When reporting the model download", func() {
+		It("should report failures of the downloader", func() {
+			status := &corev1alpha1.VllmDeploymentStatus{}
+			setModelDownloadCondition(status, 1, []corev1.Pod{{
+				ObjectMeta: metav1.ObjectMeta{Name: "pod-a"},
+				Status: corev1.PodStatus{InitContainerStatuses: []corev1.ContainerStatus{{
+					Name: "model-downloader",
+					State: corev1.ContainerState{Terminated: &corev1.ContainerStateTerminated{
+						ExitCode: 1,
+						Message:  "401 Client Error",
+					}},
+				}}},
+			}})
+
+			cond := meta.FindStatusCondition(status.Conditions, corev1alpha1.ConditionModelDownloaded)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionFalse))
+			Expect(cond.Reason).To(Equal(corev1alpha1.ReasonDownloadFailed))
+			Expect(cond.Message).To(ContainSubstring("401 Client Error"))
+		})
+	})
+
+	Context("When the Hugging Face token Secret is referenced", func() {
+		const resourceName = "gated-model"
+
+		ctx := context.Background()
+
+		typeNamespacedName := types.NamespacedName{
+			Name:      resourceName,
+			Namespace: "default",
+		}
+
+		BeforeEach(func() {
+			resource := &corev1alpha1.VllmDeployment{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      resourceName,
+					Namespace: "default",
+				},
+				Spec: corev1alpha1.VllmDeploymentSpec{
+					Replicas: ptr.To[int32](1),
+					Model: &corev1alpha1.ModelConfig{
+						Name:           "meta-llama/Llama-3.1-8B-Instruct",
+						TokenSecretRef: &corev1alpha1.SecretKeyRef{Name: "hf-token", Key: "token"},
+					},
+					VLLMConfig: &corev1alpha1.VLLMConfig{
+						Port: 8000,
+					},
+					Containers: []corev1.Container{{
+						Name:  "vllm",
+						Image: "vllm/vllm-openai:v0.6.2",
+					}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+		})
+
+		AfterEach(func() {
+			resource := &corev1alpha1.VllmDeployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			deleteAndFinalize(ctx, resource)
+		})
+
+		It("should report CredentialsMissing and inject HF_TOKEN from the Secret", func() {
+			controllerReconciler := &VllmDeploymentReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				recorder: &record.FakeRecorder{},
+			}
+
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{
+				NamespacedName: typeNamespacedName,
+			})
+			Expect(err).NotTo(HaveOccurred())
+
+			resource := &corev1alpha1.VllmDeployment{}
+			Expect(k8sClient.Get(ctx, typeNamespacedName, resource)).To(Succeed())
+			cond := meta.FindStatusCondition(resource.Status.Conditions, corev1alpha1.ConditionCredentialsMissing)
+			Expect(cond).NotTo(BeNil())
+			Expect(cond.Status).To(Equal(metav1.ConditionTrue))
+			Expect(cond.Reason).To(Equal(corev1alpha1.ReasonSecretNotFound))
+
+			deployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{
+				Name:      resourceName + "-deployment",
+				Namespace: "default",
+			}, deployment)).To(Succeed())
+			env := deployment.Spec.Template.Spec.Containers[0].Env
+			Expect(env).To(ContainElement(HaveField("ValueFrom.SecretKeyRef.Name", "hf-token")))
+		})
+	})
+
+	Context("When converting the vLLM config to arguments", func() {
+		DescribeTable("should render each engine argument",
+			func(configure func(*corev1alpha1.VLLMConfig), expected []string) {
+				spec := &corev1alpha1.VllmDeploymentSpec{
+					Model:      &corev1alpha1.ModelConfig{Name: "keeeeenw/MicroLlama"},
+					VLLMConfig: &corev1alpha1.VLLMConfig{},
+				}
+				configure(spec.VLLMConfig)
+				Expect(convertVllmConfigToArgs(spec)).To(Equal(append([]string{"--model", "keeeeenw/MicroLlama"}, expected...)))
+			},
+			Entry("tensor-parallel-size", func(vc *corev1alpha1.VLLMConfig) { vc.TensorParallelSize = 4 },
+				[]string{"--tensor-parallel-size", "4"}),
+			Entry("pipeline-parallel-size", func(vc *corev1alpha1.VLLMConfig) { vc.PipelineParallelSize = 2 },
+				[]string{"--pipeline-parallel-size", "2"}),
+			Entry("dtype", func(vc *corev1alpha1.VLLMConfig) { vc.Dtype = "bfloat16" },
+				[]string{"--dtype", "bfloat16"}),
+			Entry("quantization", func(vc *corev1alpha1.VLLMConfig) { vc.Quantization = "awq" },
+				[]string{"--quantization", "awq"}),
+			Entry("kv-cache-dtype", func(vc *corev1alpha1.VLLMConfig) { vc.KVCacheDtype = "fp8" },
+				[]string{"--kv-cache-dtype", "fp8"}),
+			Entry("max-num-seqs", func(vc *corev1alpha1.VLLMConfig) { vc.MaxNumSeqs = 128 },
+				[]string{"--max-num-seqs", "128"}),
+			Entry("max-num-batched-tokens", func(vc *corev1alpha1.VLLMConfig) { vc.MaxNumBatchedTokens = 8192 },
+				[]string{"--max-num-batched-tokens", "8192"}),
+			Entry("enable-prefix-caching", func(vc *corev1alpha1.VLLMConfig) { vc.EnablePrefixCaching = true },
+				[]string{"--enable-prefix-caching"}),
+			Entry("enable-chunked-prefill", func(vc *corev1alpha1.VLLMConfig) { vc.EnableChunkedPrefill = true },
+				[]string{"--enable-chunked-prefill"}),
+			Entry("swap-space", func(vc *corev1alpha1.VLLMConfig) { vc.SwapSpace = "0" },
+				[]string{"--swap-space", "0"}),
+			Entry("served-model-name", func(vc *corev1alpha1.VLLMConfig) { vc.ServedModelName = []string{"llama", "default"} },
+				[]string{"--served-model-name", "llama", "default"}),
+			Entry("trust-remote-code", func(vc *corev1alpha1.VLLMConfig) { vc.TrustRemoteCode = true },
+				[]string{"--trust-remote-code"}),
+			Entry("seed", func(vc *corev1alpha1.VLLMConfig) { vc.Seed = ptr.To(0) },
+				[]string{"--seed", "0"}),
+			Entry("revision", func(vc *corev1alpha1.VLLMConfig) { vc.Revision = "main" },
+				[]string{"--revision", "main"}),
+			Entry("no flag when unset", func(vc *corev1alpha1.VLLMConfig) {},
+				[]string{}),
+		)
+
+		It("should append the extra arguments after the typed flags", func() {
+			spec := &corev1alpha1.VllmDeploymentSpec{
+				Model: &corev1alpha1.ModelConfig{Name: "keeeeenw/MicroLlama"},
+				VLLMConfig: &corev1alpha1.VLLMConfig{
+					Port:         8072,
+					ExtraArgs:    []string{"--max-log-len", "100", "--disable-log-requests"},
+					ExtraArgsMap: map[string]string{"--tokenizer-mode": "mistral", "disable-frontend-multiprocessing": ""},
+				},
+			}
+			Expect(convertVllmConfigToArgs(spec)).To(Equal([]string{
+				"--model", "keeeeenw/MicroLlama",
+				"--port", "8072",
+				"--max-log-len", "100", "--disable-log-requests",
+				"--disable-frontend-multiprocessing", "--tokenizer-mode", "mistral",
+			}))
+		})
+	})
+
+	Context("When serving a model across several nodes", func() {
+		var v *corev1alpha1.VllmDeployment
+
+		BeforeEach(func() {
+			v = &corev1alpha1.VllmDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "llama-405b", Namespace: "default"},
+				Spec: corev1alpha1.VllmDeploymentSpec{
+					Replicas:   ptr.To[int32](2),
+					Model:      &corev1alpha1.ModelConfig{Name: "meta-llama/Llama-3.1-405B-Instruct"},
+					VLLMConfig: &corev1alpha1.VLLMConfig{Port: 8000},
+					Containers: []corev1.Container{{Name: "vllm", Image: "vllm/vllm-openai:v0.6.2"}},
+					GPU:        &corev1alpha1.GPUConfig{Count: 8},
+					MultiNode:  &corev1alpha1.MultiNodeConfig{Size: 2},
+				},
+			}
+		})
+
+		It("should run the Ray head and vLLM on the leader and Ray workers on the others", func() {
+			leader := constructLeaderStatefulSet(v)
+			Expect(leader.Name).To(Equal("llama-405b-leader"))
+			Expect(leader.Spec.Replicas).To(HaveValue(Equal(int32(2))))
+			Expect(leader.Spec.Selector.MatchLabels).To(HaveKeyWithValue("vllmoperator.org/role", "leader"))
+			container := leader.Spec.Template.Spec.Containers[0]
+			Expect(container.Command[2]).To(ContainSubstring("ray start --head --port=6379"))
+			Expect(container.Command[3]).To(Equal("vllm"))
+			Expect(container.Args).To(ContainElements("--tensor-parallel-size", "8"))
+			Expect(container.Args).To(ContainElements("--pipeline-parallel-size", "2"))
+			Expect(container.Args).To(ContainElements("--distributed-executor-backend", "ray"))
+
+			worker := constructWorkerStatefulSet(v)
+			Expect(worker.Spec.Replicas).To(HaveValue(Equal(int32(2))))
+			Expect(worker.Spec.Selector.MatchLabels).To(HaveKeyWithValue("vllmoperator.org/role", "worker"))
+			container = worker.Spec.Template.Spec.Containers[0]
+			Expect(container.Command[2]).To(ContainSubstring(`LEADER_ADDRESS="llama-405b-leader-$((ORDINAL / 1)).llama-405b-leader.default.svc"`))
+			Expect(container.Command[2]).To(ContainSubstring("ray start --block"))
+			Expect(container.Args).To(BeEmpty())
+			Expect(container.ReadinessProbe).To(BeNil())
+
+			Expect(constructLeaderService(v).Spec.PublishNotReadyAddresses).To(BeTrue())
+			Expect(constructService(v).Spec.Selector).To(HaveKeyWithValue("vllmoperator.org/role", "leader"))
+		})
+
+		It("should render a LeaderWorkerSet with a leader and a worker template", func() {
+			lws, err := constructLeaderWorkerSet(v)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(lws.GetKind()).To(Equal("LeaderWorkerSet"))
+			Expect(lws.GetName()).To(Equal("llama-405b-lws"))
+			replicas, _, _ := unstructured.NestedInt64(lws.Object, "spec", "replicas")
+			Expect(replicas).To(Equal(int64(2)))
+			size, _, _ := unstructured.NestedInt64(lws.Object, "spec", "leaderWorkerTemplate", "size")
+			Expect(size).To(Equal(int64(2)))
+			containers, _, _ := unstructured.NestedSlice(lws.Object, "spec", "leaderWorkerTemplate", "workerTemplate", "spec", "containers")
+			Expect(containers).To(HaveLen(1))
+			Expect(containers[0]).To(HaveKeyWithValue("command", ContainElement(ContainSubstring("LWS_LEADER_ADDRESS"))))
+		})
+
+		It("should count a group ready only when its leader and workers are", func() {
+			ready := corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}}
+			pods := []corev1.Pod{
+				{ObjectMeta: metav1.ObjectMeta{Name: "llama-405b-leader-0"}, Status: ready},
+				{ObjectMeta: metav1.ObjectMeta{Name: "llama-405b-worker-0"}, Status: ready},
+				{ObjectMeta: metav1.ObjectMeta{Name: "llama-405b-leader-1"}, Status: ready},
+				{ObjectMeta: metav1.ObjectMeta{Name: "llama-405b-worker-1"}},
+			}
+			Expect(readyGroups(v, pods)).To(Equal(int32(1)))
+
+			status := &corev1alpha1.VllmDeploymentStatus{}
+			setReplicaConditions(status, 1, replicaState{desired: 2, updated: 2, ready: 1})
+			Expect(meta.IsStatusConditionFalse(status.Conditions, corev1alpha1.ConditionReady)).To(BeTrue())
+			Expect(meta.IsStatusConditionTrue(status.Conditions, corev1alpha1.ConditionAvailable)).To(BeTrue())
+			Expect(meta.FindStatusCondition(status.Conditions, corev1alpha1.ConditionReady).Message).To(Equal("1/2 replicas ready"))
+		})
+	})
+
+	Context("When choosing the workload kind", func() {
+		var v *corev1alpha1.VllmDeployment
+
+		BeforeEach(func() {
+			v = &corev1alpha1.VllmDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "llama", Namespace: "default"},
+				Spec: corev1alpha1.VllmDeploymentSpec{
+					Replicas:   ptr.To[int32](3),
+					Model:      &corev1alpha1.ModelConfig{Name: "meta-llama/Llama-3.1-8B-Instruct"},
+					VLLMConfig: &corev1alpha1.VLLMConfig{Port: 8000},
+					Containers: []corev1.Container{{Name: "vllm", Image: "vllm/vllm-openai:v0.6.2"}},
+				},
+			}
+		})
+
+		It("should run single-node replicas as a StatefulSet", func() {
+			objects, err := statefulSetWorkload{}.desired(v)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(objects).To(HaveLen(2))
+			sts := objects[0].(*appsv1.StatefulSet)
+			Expect(sts.Name).To(Equal("llama-statefulset"))
+			Expect(sts.Spec.Replicas).To(HaveValue(Equal(int32(3))))
+			// the governing Service must be headless for the pods to get their DNS name
+			headless := objects[1].(*corev1.Service)
+			Expect(sts.Spec.ServiceName).To(Equal(headless.Name))
+			Expect(headless.Name).To(Equal("llama-headless"))
+			Expect(headless.Spec.ClusterIP).To(Equal(corev1.ClusterIPNone))
+			Expect(headless.Spec.Selector).To(Equal(map[string]string{"app": "llama"}))
+			Expect(sts.Spec.Template.Spec.Containers[0].Args).To(ContainElements("--model", "meta-llama/Llama-3.1-8B-Instruct"))
+
+			sts.Status = appsv1.StatefulSetStatus{ReadyReplicas: 3, UpdatedReplicas: 3}
+			status := constructStatus(v, statefulSetWorkload{}, objects, nil)
+			Expect(status.WorkloadKind).To(Equal(corev1alpha1.WorkloadKindStatefulSet))
+			Expect(status.ReadyReplicas).To(Equal(int32(3)))
+			Expect(meta.IsStatusConditionTrue(status.Conditions, corev1alpha1.ConditionReady)).To(BeTrue())
+		})
+
+		It("should run single-node replicas as LeaderWorkerSet groups of one pod", func() {
+			lws, err := constructLeaderWorkerSet(v)
+			Expect(err).NotTo(HaveOccurred())
+			size, _, _ := unstructured.NestedInt64(lws.Object, "spec", "leaderWorkerTemplate", "size")
+			Expect(size).To(Equal(int64(1)))
+			_, hasLeaderTemplate, _ := unstructured.NestedMap(lws.Object, "spec", "leaderWorkerTemplate", "leaderTemplate")
+			Expect(hasLeaderTemplate).To(BeFalse())
+			containers, _, _ := unstructured.NestedSlice(lws.Object, "spec", "leaderWorkerTemplate", "workerTemplate", "spec", "containers")
+			Expect(containers).To(HaveLen(1))
+			Expect(containers[0]).To(HaveKeyWithValue("args", ContainElement("--model")))
+		})
+
+		It("should scale the workload to zero replicas", func() {
+			for _, kind := range []corev1alpha1.WorkloadKind{corev1alpha1.WorkloadKindDeployment, corev1alpha1.WorkloadKindStatefulSet} {
+				resource := &corev1alpha1.VllmDeployment{
+					ObjectMeta: metav1.ObjectMeta{Name: "scale-" + strings.ToLower(string(kind)), Namespace: "default"},
+					Spec: corev1alpha1.VllmDeploymentSpec{
+						Replicas:     ptr.To[int32](2),
+						Model:        &corev1alpha1.ModelConfig{Name: "keeeeenw/MicroLlama"},
+						VLLMConfig:   &corev1alpha1.VLLMConfig{Port: 8072},
+						Containers:   []corev1.Container{{Name: "vllm", Image: "vllm/vllm-openai:v0.6.2"}},
+						WorkloadKind: kind,
+					},
+				}
+				Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+				DeferCleanup(func() {
+					deleteAndFinalize(ctx, resource)
+				})
+				r := &VllmDeploymentReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), recorder: &record.FakeRecorder{}}
+				request := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(resource)}
+				_, err := r.Reconcile(ctx, request)
+				Expect(err).NotTo(HaveOccurred())
+
+				// as kubectl scale --replicas=0 does through the scale subresource
+				Expect(k8sClient.Get(ctx, request.NamespacedName, resource)).To(Succeed())
+				resource.Spec.Replicas = ptr.To[int32](0)
+				Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+				_, err = r.Reconcile(ctx, request)
+				Expect(err).NotTo(HaveOccurred())
+
+				var workload client.Object = &appsv1.Deployment{}
+				workloadKey := types.NamespacedName{Name: deploymentName(resource), Namespace: "default"}
+				if kind == corev1alpha1.WorkloadKindStatefulSet {
+					workload = &appsv1.StatefulSet{}
+					workloadKey.Name = statefulSetName(resource)
+				}
+				Expect(k8sClient.Get(ctx, workloadKey, workload)).To(Succeed())
+				Expect(workloadReplicas(workload)).To(Equal(int32(0)), string(kind))
+			}
+		})
+
+		It("should reject a Deployment for multi-node groups", func() {
+			v.Spec.WorkloadKind = corev1alpha1.WorkloadKindDeployment
+			v.Spec.MultiNode = &corev1alpha1.MultiNodeConfig{Size: 2}
+			Expect(validateSpec(&v.Spec)).To(MatchError(ContainSubstring("cannot run spec.multiNode groups")))
+		})
+	})
+
+	Context("When autoscaling on the vLLM metrics", func() {
+		var v *corev1alpha1.VllmDeployment
+
+		BeforeEach(func() {
+			v = &corev1alpha1.VllmDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "llama", Namespace: "default"},
+				Spec: corev1alpha1.VllmDeploymentSpec{
+					Replicas:   ptr.To[int32](1),
+					Model:      &corev1alpha1.ModelConfig{Name: "meta-llama/Llama-3.1-8B-Instruct"},
+					VLLMConfig: &corev1alpha1.VLLMConfig{Port: 8000},
+					Containers: []corev1.Container{{Name: "vllm", Image: "vllm/vllm-openai:v0.6.2"}},
+					Autoscaling: &corev1alpha1.AutoscalingConfig{
+						MinReplicas:        ptr.To[int32](2),
+						MaxReplicas:        8,
+						NumRequestsWaiting: &corev1alpha1.MetricTarget{AverageValue: resource.MustParse("5")},
+						GPUCacheUsage:      &corev1alpha1.MetricTarget{AverageValue: resource.MustParse("800m")},
+						TokensPerSecond:    &corev1alpha1.MetricTarget{AverageValue: resource.MustParse("1k"), MetricName: "vllm_tokens_rate"},
+					},
+				},
+			}
+		})
+
+		It("should scale the workload on pods metrics", func() {
+			hpa, err := constructHorizontalPodAutoscaler(v, corev1alpha1.WorkloadKindDeployment)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hpa.Name).To(Equal("llama-hpa"))
+			Expect(hpa.Spec.ScaleTargetRef.Kind).To(Equal("Deployment"))
+			Expect(hpa.Spec.ScaleTargetRef.Name).To(Equal("llama-deployment"))
+			Expect(hpa.Spec.MinReplicas).To(HaveValue(Equal(int32(2))))
+			Expect(hpa.Spec.MaxReplicas).To(Equal(int32(8)))
+			Expect(hpa.Spec.Metrics).To(HaveLen(3))
+			Expect(hpa.Spec.Metrics[0].Pods.Metric.Name).To(Equal("vllm:num_requests_waiting"))
+			Expect(hpa.Spec.Metrics[1].Pods.Metric.Name).To(Equal("vllm:gpu_cache_usage_perc"))
+			Expect(hpa.Spec.Metrics[1].Pods.Target.AverageValue.MilliValue()).To(Equal(int64(800)))
+			Expect(hpa.Spec.Metrics[2].Pods.Metric.Name).To(Equal("vllm_tokens_rate"))
+
+			Expect(constructDeployment(v).Spec.Replicas).To(HaveValue(Equal(int32(2))))
+		})
+
+		It("should select external metrics by the app label", func() {
+			v.Spec.Autoscaling.MetricType = corev1alpha1.AutoscalingMetricTypeExternal
+			hpa, err := constructHorizontalPodAutoscaler(v, corev1alpha1.WorkloadKindLeaderWorkerSet)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(hpa.Spec.ScaleTargetRef.APIVersion).To(Equal("leaderworkerset.x-k8s.io/v1"))
+			Expect(hpa.Spec.ScaleTargetRef.Name).To(Equal("llama-lws"))
+			Expect(hpa.Spec.Metrics[0].External.Metric.Selector.MatchLabels).To(HaveKeyWithValue("app", "llama"))
+		})
+
+		It("should not scale multi-node StatefulSets", func() {
+			v.Spec.MultiNode = &corev1alpha1.MultiNodeConfig{Size: 2}
+			_, err := constructHorizontalPodAutoscaler(v, corev1alpha1.WorkloadKindStatefulSet)
+			Expect(err).To(MatchError(ContainSubstring("LeaderWorkerSet")))
+		})
+
+		It("should leave the replicas to the autoscaler", func() {
+			v.Name = "autoscaled"
+			Expect(k8sClient.Create(ctx, v)).To(Succeed())
+			DeferCleanup(func() {
+				deleteAndFinalize(ctx, v)
+			})
+			r := &VllmDeploymentReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), recorder: &record.FakeRecorder{}}
+			request := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(v)}
+			deploymentKey := types.NamespacedName{Name: "autoscaled-deployment", Namespace: "default"}
+			_, err := r.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			_, err = r.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Scaling the Deployment as the HPA does")
+			deployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, deploymentKey, deployment)).To(Succeed())
+			Expect(deployment.Spec.Replicas).To(HaveValue(Equal(int32(2))))
+			deployment.Spec.Replicas = ptr.To[int32](6)
+			Expect(k8sClient.Update(ctx, deployment)).To(Succeed())
+
+			_, err = r.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, deploymentKey, deployment)).To(Succeed())
+			Expect(deployment.Spec.Replicas).To(HaveValue(Equal(int32(6))))
+			for _, entry := range deployment.ManagedFields {
+				if entry.Manager == fieldManager {
+					Expect(string(entry.FieldsV1.Raw)).NotTo(ContainSubstring(`"f:replicas"`))
+				}
+			}
+
+			lws, err := constructLeaderWorkerSet(v)
+			Expect(err).NotTo(HaveOccurred())
+			omitReplicas(lws)
+			_, found, _ := unstructured.NestedInt64(lws.Object, "spec", "replicas")
+			Expect(found).To(BeFalse())
+		})
+	})
+
+	Context("When autoscaling from the operator", func() {
+		var v *corev1alpha1.VllmDeployment
+
+		BeforeEach(func() {
+			v = &corev1alpha1.VllmDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "llama", Namespace: "default"},
+				Spec: corev1alpha1.VllmDeploymentSpec{
+					Autoscaling: &corev1alpha1.AutoscalingConfig{
+						Provider:           corev1alpha1.AutoscalingProviderOperator,
+						MinReplicas:        ptr.To[int32](1),
+						MaxReplicas:        10,
+						NumRequestsWaiting: &corev1alpha1.MetricTarget{AverageValue: resource.MustParse("4")},
+						NumRequestsRunning: &corev1alpha1.MetricTarget{AverageValue: resource.MustParse("16")},
+					},
+				},
+			}
+		})
+
+		It("should sum the vLLM metrics of a pod over their labels", func() {
+			metrics, err := parseMetrics(strings.NewReader(`# HELP vllm:num_requests_waiting Number of requests waiting to be processed.
+# TYPE vllm:num_requests_waiting gauge
+vllm:num_requests_waiting{model_name="a"} 3.0
+vllm:num_requests_waiting{model_name="b"} 2.0
+# TYPE vllm:num_requests_running gauge
+vllm:num_requests_running{model_name="a"} 8.0
+`))
+			Expect(err).NotTo(HaveOccurred())
+			Expect(metrics).To(HaveKeyWithValue("vllm:num_requests_waiting", 5.0))
+			Expect(metrics).To(HaveKeyWithValue("vllm:num_requests_running", 8.0))
+		})
+
+		DescribeTable("should recommend the replicas of the most demanding target",
+			func(samples []map[string]float64, current, expected int32) {
+				replicas, _, ok := recommendReplicas(&v.Spec, current, samples)
+				Expect(ok).To(BeTrue())
+				Expect(replicas).To(Equal(expected))
+			},
+			Entry("queue above target", []map[string]float64{
+				{"vllm:num_requests_waiting": 10, "vllm:num_requests_running": 16},
+				{"vllm:num_requests_waiting": 14, "vllm:num_requests_running": 16},
+			}, int32(2), int32(6)),
+			Entry("running requests above target", []map[string]float64{
+				{"vllm:num_requests_running": 40},
+			}, int32(1), int32(3)),
+			Entry("within tolerance", []map[string]float64{
+				{"vllm:num_requests_waiting": 4.2}, {"vllm:num_requests_waiting": 4},
+			}, int32(2), int32(2)),
+			Entry("idle down to minReplicas", []map[string]float64{
+				{}, {}, {},
+			}, int32(3), int32(1)),
+			Entry("capped at maxReplicas", []map[string]float64{
+				{"vllm:num_requests_waiting": 100},
+			}, int32(1), int32(10)),
+		)
+
+		It("should not recommend anything without metrics", func() {
+			_, _, ok := recommendReplicas(&v.Spec, 2, nil)
+			Expect(ok).To(BeFalse())
+		})
+
+		It("should stabilize scale downs over the window", func() {
+			a := newMetricsAutoscaler()
+			key := client.ObjectKeyFromObject(v)
+			start := time.Now()
+			at := func(d time.Duration) time.Time { return start.Add(d) }
+
+			Expect(a.stabilize(key, recommendation{replicas: 5, timestamp: at(0)}, 2, 0, 5*time.Minute)).To(Equal(int32(5)))
+			Expect(a.stabilize(key, recommendation{replicas: 1, timestamp: at(time.Minute)}, 5, 0, 5*time.Minute)).To(Equal(int32(5)))
+			Expect(a.stabilize(key, recommendation{replicas: 2, timestamp: at(4 * time.Minute)}, 5, 0, 5*time.Minute)).To(Equal(int32(5)))
+			Expect(a.stabilize(key, recommendation{replicas: 1, timestamp: at(6 * time.Minute)}, 5, 0, 5*time.Minute)).To(Equal(int32(2)))
+
+			Expect(a.due(key, at(0), 15*time.Second)).To(BeTrue())
+			Expect(a.due(key, at(10*time.Second), 15*time.Second)).To(BeFalse())
+			Expect(a.due(key, at(15*time.Second), 15*time.Second)).To(BeTrue())
+		})
+
+		It("should warn once when scraping the ready pods starts failing", func() {
+			ctx := context.Background()
+			v.Name = "unscraped"
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "unscraped-0", Namespace: "default", Labels: map[string]string{"app": "unscraped"}},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "vllm", Image: "vllm/vllm-openai:v0.6.2"}}},
+			}
+			Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, pod)).To(Succeed())
+			})
+			pod.Status.Conditions = []corev1.PodCondition{{Type: corev1.PodReady, Status: corev1.ConditionTrue}}
+			Expect(k8sClient.Status().Update(ctx, pod)).To(Succeed())
+
+			scraper := &stubScraper{err: errors.NewServiceUnavailable("connection refused")}
+			a := newMetricsAutoscaler()
+			a.scraper = scraper
+			now := time.Now()
+			a.now = func() time.Time { return now }
+			recorder := record.NewFakeRecorder(10)
+			r := &VllmDeploymentReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), recorder: recorder, autoscaler: a}
+			objects := []client.Object{&appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "unscraped-deployment", Namespace: "default"},
+				Spec:       appsv1.DeploymentSpec{Replicas: ptr.To[int32](2)},
+			}}
+			sync := func() {
+				_, err := r.autoscale(ctx, v, corev1alpha1.WorkloadKindDeployment, objects)
+				Expect(err).NotTo(HaveOccurred())
+				now = now.Add(time.Minute)
+			}
+
+			sync()
+			Expect(recorder.Events).To(Receive(Equal(
+				"Warning MetricsUnavailable No ready pod of unscraped-deployment could be scraped, keeping 2 replicas")))
+			sync()
+			Expect(recorder.Events).NotTo(Receive())
+
+			// again once scraping recovered and failed anew
+			scraper.err = nil
+			scraper.metrics = map[string]float64{"vllm:num_requests_waiting": 4, "vllm:num_requests_running": 16}
+			sync()
+			Expect(recorder.Events).NotTo(Receive())
+			scraper.err = errors.NewServiceUnavailable("connection refused")
+			sync()
+			Expect(recorder.Events).To(Receive(HavePrefix("Warning MetricsUnavailable ")))
+		})
+
+		It("should wait for the cooldown after a scaling", func() {
+			now := time.Now()
+			lastScale := &metav1.Time{Time: now.Add(-2 * time.Minute)}
+			Expect(withinCooldown(2, 4, lastScale, now, time.Minute, 5*time.Minute)).To(BeFalse())
+			Expect(withinCooldown(4, 2, lastScale, now, time.Minute, 5*time.Minute)).To(BeTrue())
+			Expect(withinCooldown(4, 2, nil, now, time.Minute, 5*time.Minute)).To(BeFalse())
+		})
+	})
+
+	Context("When scaling to zero on idle", func() {
+		var v *corev1alpha1.VllmDeployment
+
+		BeforeEach(func() {
+			v = &corev1alpha1.VllmDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "llama", Namespace: "default"},
+				Spec: corev1alpha1.VllmDeploymentSpec{
+					Replicas:   ptr.To[int32](2),
+					Model:      &corev1alpha1.ModelConfig{Name: "meta-llama/Llama-3.1-8B"},
+					VLLMConfig: &corev1alpha1.VLLMConfig{Port: 8000},
+					Containers: []corev1.Container{{Name: "vllm", Image: "vllm/vllm-openai:latest"}},
+					Idle:       &corev1alpha1.IdleConfig{After: metav1.Duration{Duration: 30 * time.Minute}},
+				},
+			}
+		})
+
+		It("should track the last request from the request counters", func() {
+			a := newMetricsAutoscaler()
+			key := client.ObjectKeyFromObject(v)
+			start := time.Now()
+			at := func(d time.Duration) time.Time { return start.Add(d) }
+
+			Expect(a.observeRequests(key, at(0), 10, false)).To(Equal(at(0)))
+			Expect(a.observeRequests(key, at(time.Minute), 12, false)).To(Equal(at(time.Minute)))
+			Expect(a.observeRequests(key, at(2*time.Minute), 12, true)).To(Equal(at(2 * time.Minute)))
+			Expect(a.observeRequests(key, at(40*time.Minute), 12, false)).To(Equal(at(2 * time.Minute)))
+
+			Expect(a.idleDue(key, at(0))).To(BeTrue())
+			Expect(a.idleDue(key, at(10*time.Second))).To(BeFalse())
+
+			a.forgetIdle(key)
+			Expect(a.observeRequests(key, at(41*time.Minute), 0, false)).To(Equal(at(41 * time.Minute)))
+		})
+
+		It("should route the Service to the activator while scaled to zero", func() {
+			Expect(desiredReplicas(v)).To(Equal(int32(2)))
+			Expect(constructService(v).Spec.Selector).To(Equal(map[string]string{"app": "llama"}))
+
+			v.Status.Idle = &corev1alpha1.IdleStatus{ScaledToZeroTime: &metav1.Time{Time: time.Now()}}
+			Expect(desiredReplicas(v)).To(Equal(int32(0)))
+			Expect(constructService(v).Spec.Selector).To(Equal(map[string]string{activatorLabel: "llama"}))
+			Expect(constructService(v).Spec.Ports[0].TargetPort).To(Equal(intstr.FromInt32(activatorPort)))
+			Expect(constructBackendService(v).Spec.Selector).To(Equal(map[string]string{"app": "llama"}))
+
+			// the requests stay on the activator until a replica is ready
+			v.Status.Idle.WakeTime = &metav1.Time{Time: time.Now()}
+			Expect(desiredReplicas(v)).To(Equal(int32(2)))
+			Expect(constructService(v).Spec.Selector).To(Equal(map[string]string{activatorLabel: "llama"}))
+
+			v.Spec.Idle = nil
+			Expect(desiredReplicas(v)).To(Equal(int32(2)))
+			Expect(constructService(v).Spec.Selector).To(Equal(map[string]string{"app": "llama"}))
+			Expect(constructService(v).Spec.Ports[0].TargetPort).To(Equal(intstr.FromInt32(8000)))
+		})
+
+		It("should run the activator in front of the backend Service", func() {
+			// the activator runs as non-root, it never listens on a privileged vLLM port
+			v.Spec.VLLMConfig.Port = 80
+			d := constructActivatorDeployment(v, "controller:latest")
+			Expect(d.Name).To(Equal("llama-activator"))
+			Expect(d.Spec.Template.Labels).NotTo(HaveKey("app"))
+			container := d.Spec.Template.Spec.Containers[0]
+			Expect(container.Image).To(Equal("controller:latest"))
+			Expect(container.Args).To(ConsistOf(
+				"--port=8080",
+				"--metrics-port=9090",
+				"--backend=http://llama-backend.default.svc:80",
+				"--timeout=15m0s",
+			))
+		})
+
+		It("should report the ScaledToZero condition", func() {
+			status := &corev1alpha1.VllmDeploymentStatus{}
+			setIdleCondition(status, v)
+			Expect(meta.IsStatusConditionFalse(status.Conditions, corev1alpha1.ConditionScaledToZero)).To(BeTrue())
+
+			status.Idle = &corev1alpha1.IdleStatus{ScaledToZeroTime: &metav1.Time{Time: time.Now()}}
+			setIdleCondition(status, v)
+			c := meta.FindStatusCondition(status.Conditions, corev1alpha1.ConditionScaledToZero)
+			Expect(c.Status).To(Equal(metav1.ConditionTrue))
+			Expect(c.Reason).To(Equal(corev1alpha1.ReasonIdle))
+
+			status.Idle.WakeTime = &metav1.Time{Time: time.Now()}
+			setIdleCondition(status, v)
+			Expect(meta.FindStatusCondition(status.Conditions, corev1alpha1.ConditionScaledToZero).Reason).To(Equal(corev1alpha1.ReasonWakingUp))
+
+			v.Spec.Idle = nil
+			setIdleCondition(status, v)
+			Expect(meta.FindStatusCondition(status.Conditions, corev1alpha1.ConditionScaledToZero)).To(BeNil())
+		})
+	})
+
+	Context("When deleting a resource", func() {
+		ctx := context.Background()
+
+		newResource := func(name string) *corev1alpha1.VllmDeployment {
+			size := resource.MustParse("20Gi")
+			return &corev1alpha1.VllmDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+				Spec: corev1alpha1.VllmDeploymentSpec{
+					Model: &corev1alpha1.ModelConfig{
+						Name:  "keeeeenw/MicroLlama",
+						Cache: &corev1alpha1.ModelCacheConfig{Size: &size},
+					},
+					VLLMConfig: &corev1alpha1.VLLMConfig{Port: 8072},
+					Containers: []corev1.Container{{Name: "vllm", Image: "vllm/vllm-openai:v0.6.2"}},
+				},
+			}
+		}
+
+		reconcileOnce := func(obj *corev1alpha1.VllmDeployment) {
+			controllerReconciler := &VllmDeploymentReconciler{
+				Client:   k8sClient,
+				Scheme:   k8sClient.Scheme(),
+				recorder: &record.FakeRecorder{},
+			}
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(obj)})
+			Expect(err).NotTo(HaveOccurred())
+		}
+
+		It("should drain the traffic and delete the model cache before releasing the resource", func() {
+			obj := newResource("delete-policy")
+			Expect(k8sClient.Create(ctx, obj)).To(Succeed())
+			reconcileOnce(obj)
+
+			Expect(k8sClient.Get(ctx, client.ObjectKeyFromObject(obj), obj)).To(Succeed())
+			Expect(obj.Finalizers).To(ContainElement(cleanupFinalizer))
+			serviceKey := types.NamespacedName{Name: "delete-policy-service", Namespace: "default"}
+			Expect(k8sClient.Get(ctx, serviceKey, &corev1.Service{})).To(Succeed())
+
+			deleteAndFinalize(ctx, obj)
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, serviceKey, &corev1.Service{}))).To(BeTrue())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, types.NamespacedName{Name: "delete-policy-deployment", Namespace: "default"},
+				&appsv1.Deployment{}))).To(BeTrue())
+			claim := &corev1.PersistentVolumeClaim{}
+			err := k8sClient.Get(ctx, types.NamespacedName{Name: "delete-policy-model-cache", Namespace: "default"}, claim)
+			// the claim may linger while the pvc-protection finalizer holds it
+			Expect(errors.IsNotFound(err) || !claim.DeletionTimestamp.IsZero()).To(BeTrue())
+		})
+
+		It("should record the drain once while the pods terminate", func() {
+			obj := newResource("draining")
+			Expect(k8sClient.Create(ctx, obj)).To(Succeed())
+			reconcileOnce(obj)
+			pod := &corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "draining-0", Namespace: "default", Labels: map[string]string{"app": "draining"}},
+				Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "vllm", Image: "vllm/vllm-openai:v0.6.2"}}},
+			}
+			Expect(k8sClient.Create(ctx, pod)).To(Succeed())
+
+			Expect(k8sClient.Delete(ctx, obj)).To(Succeed())
+			recorder := record.NewFakeRecorder(10)
+			controllerReconciler := &VllmDeploymentReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), recorder: recorder}
+			for i := 0; i < 2; i++ {
+				result, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(obj)})
+				Expect(err).NotTo(HaveOccurred())
+				Expect(result.RequeueAfter).To(Equal(drainPollInterval))
+			}
+			Expect(recorder.Events).To(Receive(Equal("Normal Draining Waiting for 1 pods to finish their requests and terminate")))
+			Expect(recorder.Events).NotTo(Receive())
+
+			Expect(k8sClient.Delete(ctx, pod)).To(Succeed())
+			_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(obj)})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(errors.IsNotFound(k8sClient.Get(ctx, client.ObjectKeyFromObject(obj), &corev1alpha1.VllmDeployment{}))).To(BeTrue())
+		})
+
+		It("should keep the model cache with the Retain policy", func() {
+			obj := newResource("retain-policy")
+			obj.Spec.DeletionPolicy = corev1alpha1.DeletionPolicyRetain
+			Expect(k8sClient.Create(ctx, obj)).To(Succeed())
+			reconcileOnce(obj)
+
+			deleteAndFinalize(ctx, obj)
+			claim := &corev1.PersistentVolumeClaim{}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: "retain-policy-model-cache", Namespace: "default"}, claim)).To(Succeed())
+			Expect(claim.DeletionTimestamp.IsZero()).To(BeTrue())
+			Expect(claim.OwnerReferences).To(BeEmpty())
+		})
+	})
+
+	Context("When recording events", func() {
+		var (
+			v        *corev1alpha1.VllmDeployment
+			recorder *record.FakeRecorder
+			r        *VllmDeploymentReconciler
+		)
+
+		BeforeEach(func() {
+			v = &corev1alpha1.VllmDeployment{ObjectMeta: metav1.ObjectMeta{Name: "events", Namespace: "default"}}
+			recorder = record.NewFakeRecorder(10)
+			r = &VllmDeploymentReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), recorder: recorder}
+		})
+
+		It("should record the rollout completion and failures once", func() {
+			previous := &corev1alpha1.VllmDeploymentStatus{}
+			current := &corev1alpha1.VllmDeploymentStatus{Replicas: 2, ReadyReplicas: 2}
+			meta.SetStatusCondition(&current.Conditions, metav1.Condition{
+				Type: corev1alpha1.ConditionReady, Status: metav1.ConditionTrue, Reason: corev1alpha1.ReasonAllReplicasReady,
+			})
+			meta.SetStatusCondition(&current.Conditions, metav1.Condition{
+				Type: corev1alpha1.ConditionModelDownloaded, Status: metav1.ConditionFalse,
+				Reason: corev1alpha1.ReasonDownloadFailed, Message: "401 Unauthorized",
+			})
+
+			r.recordStatusEvents(v, previous, current, nil)
+			Expect(recorder.Events).To(Receive(Equal("Normal RolloutComplete Rollout complete: 2/2 replicas ready")))
+			Expect(recorder.Events).To(Receive(Equal("Warning ModelDownloadFailed 401 Unauthorized")))
+
+			r.recordStatusEvents(v, current, current, nil)
+			Expect(recorder.Events).NotTo(Receive())
+		})
+
+		It("should record the crash-looping containers", func() {
+			pod := corev1.Pod{
+				ObjectMeta: metav1.ObjectMeta{Name: "events-0"},
+				Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+					Name:         "vllm",
+					RestartCount: 3,
+					State:        corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+					LastTerminationState: corev1.ContainerState{
+						Terminated: &corev1.ContainerStateTerminated{ExitCode: 137, Reason: "OOMKilled"},
+					},
+				}}},
+			}
+
+			previous := &corev1alpha1.VllmDeploymentStatus{Restarts: 2}
+			current := &corev1alpha1.VllmDeploymentStatus{Restarts: containerRestarts([]corev1.Pod{pod})}
+			r.recordStatusEvents(v, previous, current, []corev1.Pod{pod})
+			Expect(recorder.Events).To(Receive(Equal(
+				"Warning PodCrashLooping Container vllm of pod events-0 is crash looping after 3 restarts, last exit code 137 (OOMKilled)")))
+
+			// not again until the container restarts
+			r.recordStatusEvents(v, current, current, []corev1.Pod{pod})
+			Expect(recorder.Events).NotTo(Receive())
+		})
+
+		It("should only record the crash-looping containers that restarted", func() {
+			crashLooping := func(name string, restarts int32) corev1.Pod {
+				return corev1.Pod{
+					ObjectMeta: metav1.ObjectMeta{Name: name, UID: types.UID(name)},
+					Status: corev1.PodStatus{ContainerStatuses: []corev1.ContainerStatus{{
+						Name:         "vllm",
+						RestartCount: restarts,
+						State:        corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "CrashLoopBackOff"}},
+					}}},
+				}
+			}
+			pods := []corev1.Pod{crashLooping("events-0", 3), crashLooping("events-1", 5)}
+			previous := &corev1alpha1.VllmDeploymentStatus{}
+			current := &corev1alpha1.VllmDeploymentStatus{Restarts: containerRestarts(pods)}
+			r.recordStatusEvents(v, previous, current, pods)
+			Expect(recorder.Events).To(Receive(ContainSubstring("pod events-0 is crash looping after 3 restarts")))
+			Expect(recorder.Events).To(Receive(ContainSubstring("pod events-1 is crash looping after 5 restarts")))
+
+			pods[1] = crashLooping("events-1", 6)
+			previous, current = current, &corev1alpha1.VllmDeploymentStatus{Restarts: containerRestarts(pods)}
+			r.recordStatusEvents(v, previous, current, pods)
+			Expect(recorder.Events).To(Receive(ContainSubstring("pod events-1 is crash looping after 6 restarts")))
+			Expect(recorder.Events).NotTo(Receive())
+		})
+
+		It("should record the scaling of the workload", func() {
+			r.recordScaling(v, &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "events-deployment"}}, 3, 1, "spec.replicas changed")
+			Expect(recorder.Events).To(Receive(Equal("Normal ScaledDown Scaled events-deployment from 3 to 1 replicas: spec.replicas changed")))
+		})
+
+		It("should record the objects created for a resource", func() {
+			resource := &corev1alpha1.VllmDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "events", Namespace: "default"},
+				Spec: corev1alpha1.VllmDeploymentSpec{
+					Model:      &corev1alpha1.ModelConfig{Name: "keeeeenw/MicroLlama"},
+					VLLMConfig: &corev1alpha1.VLLMConfig{Port: 8072},
+					Containers: []corev1.Container{{Name: "vllm", Image: "vllm/vllm-openai:v0.6.2"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			DeferCleanup(func() {
+				deleteAndFinalize(ctx, resource)
+			})
+
+			_, err := r.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(resource)})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(recorder.Events).To(Receive(Equal("Normal Created Created Service events-service")))
+			Expect(recorder.Events).To(Receive(Equal("Normal Created Created Deployment events-deployment")))
+		})
+	})
+
+	Context("When applying the owned objects", func() {
+		It("should leave the fields it does not own alone", func() {
+			resource := &corev1alpha1.VllmDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "apply", Namespace: "default"},
+				Spec: corev1alpha1.VllmDeploymentSpec{
+					Model:      &corev1alpha1.ModelConfig{Name: "keeeeenw/MicroLlama"},
+					VLLMConfig: &corev1alpha1.VLLMConfig{Port: 8072},
+					Containers: []corev1.Container{{Name: "vllm", Image: "vllm/vllm-openai:v0.6.2"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			DeferCleanup(func() {
+				deleteAndFinalize(ctx, resource)
+			})
+			r := &VllmDeploymentReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), recorder: &record.FakeRecorder{}}
+			request := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(resource)}
+
+			_, err := r.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			deployment := &appsv1.Deployment{}
+			deploymentKey := types.NamespacedName{Name: "apply-deployment", Namespace: "default"}
+			Expect(k8sClient.Get(ctx, deploymentKey, deployment)).To(Succeed())
+			deployment.Spec.MinReadySeconds = 30
+			Expect(k8sClient.Update(ctx, deployment)).To(Succeed())
+
+			_, err = r.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			applied := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, deploymentKey, applied)).To(Succeed())
+			Expect(applied.ResourceVersion).To(Equal(deployment.ResourceVersion))
+			Expect(applied.Spec.MinReadySeconds).To(Equal(int32(30)))
+		})
+	})
+
+	Context("When hashing the configuration of the pods", func() {
+		var v *corev1alpha1.VllmDeployment
+
+		BeforeEach(func() {
+			v = &corev1alpha1.VllmDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "config-hash", Namespace: "default"},
+				Spec: corev1alpha1.VllmDeploymentSpec{
+					Model: &corev1alpha1.ModelConfig{
+						Name:           "meta-llama/Llama-3.1-8B-Instruct",
+						TokenSecretRef: &corev1alpha1.SecretKeyRef{Name: "hf-token"},
+					},
+					VLLMConfig: &corev1alpha1.VLLMConfig{Port: 8000},
+					Containers: []corev1.Container{{
+						Name:    "vllm",
+						Image:   "vllm/vllm-openai:v0.6.2",
+						EnvFrom: []corev1.EnvFromSource{{ConfigMapRef: &corev1.ConfigMapEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "vllm-env"}}}},
+					}},
+					Volumes: []corev1.Volume{{
+						Name: "chat-template",
+						VolumeSource: corev1.VolumeSource{ConfigMap: &corev1.ConfigMapVolumeSource{
+							LocalObjectReference: corev1.LocalObjectReference{Name: "config-hash-chat-template"},
+						}},
+					}},
+				},
+			}
+		})
+
+		It("should list the ConfigMaps and Secrets read by the pods", func() {
+			Expect(configReferences(v)).To(Equal([]corev1alpha1.ConfigReference{
+				{Kind: corev1alpha1.ConfigReferenceConfigMap, Name: "config-hash-chat-template"},
+				{Kind: corev1alpha1.ConfigReferenceConfigMap, Name: "vllm-env"},
+				{Kind: corev1alpha1.ConfigReferenceSecret, Name: "hf-token"},
+			}))
+
+			v.Spec.IgnoreConfigChanges = []corev1alpha1.ConfigReference{{Kind: corev1alpha1.ConfigReferenceSecret, Name: "hf-token"}}
+			Expect(configReferences(v)).NotTo(ContainElement(HaveField("Kind", corev1alpha1.ConfigReferenceSecret)))
+		})
+
+		It("should roll out the pods when a referenced ConfigMap changes", func() {
+			v.Spec.Model.TokenSecretRef = nil
+			v.Spec.Containers[0].EnvFrom = nil
+			configMap := &corev1.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "config-hash-chat-template", Namespace: "default"},
+				Data:       map[string]string{"template.jinja": "{{ messages }}"},
+			}
+			Expect(k8sClient.Create(ctx, configMap)).To(Succeed())
+			DeferCleanup(func() {
+				Expect(k8sClient.Delete(ctx, configMap)).To(Succeed())
+			})
+			Expect(k8sClient.Create(ctx, v)).To(Succeed())
+			DeferCleanup(func() {
+				deleteAndFinalize(ctx, v)
+			})
+			r := &VllmDeploymentReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), recorder: &record.FakeRecorder{}}
+			request := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(v)}
+			deploymentKey := types.NamespacedName{Name: "config-hash-deployment", Namespace: "default"}
+
+			_, err := r.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			deployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, deploymentKey, deployment)).To(Succeed())
+			hash := deployment.Spec.Template.Annotations[configHashAnnotation]
+			Expect(hash).NotTo(BeEmpty())
+
+			configMap.Data["template.jinja"] = "{{ bos_token }}{{ messages }}"
+			Expect(k8sClient.Update(ctx, configMap)).To(Succeed())
+			// the ConfigMaps are watched through their metadata only
+			changed := &metav1.PartialObjectMetadata{ObjectMeta: configMap.ObjectMeta}
+			Expect(r.vllmDeploymentsReading(corev1alpha1.ConfigReferenceConfigMap)(ctx, changed)).To(ConsistOf(request))
+			_, err = r.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, deploymentKey, deployment)).To(Succeed())
+			Expect(deployment.Spec.Template.Annotations).To(HaveKey(configHashAnnotation))
+			Expect(deployment.Spec.Template.Annotations[configHashAnnotation]).NotTo(Equal(hash))
+		})
+	})
+
+	Context("When paused", func() {
+		It("should leave the hand-edited objects alone and report the status", func() {
+			resource := &corev1alpha1.VllmDeployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "paused", Namespace: "default"},
+				Spec: corev1alpha1.VllmDeploymentSpec{
+					Model:      &corev1alpha1.ModelConfig{Name: "keeeeenw/MicroLlama"},
+					VLLMConfig: &corev1alpha1.VLLMConfig{Port: 8072},
+					Containers: []corev1.Container{{Name: "vllm", Image: "vllm/vllm-openai:v0.6.2"}},
+				},
+			}
+			Expect(k8sClient.Create(ctx, resource)).To(Succeed())
+			DeferCleanup(func() {
+				deleteAndFinalize(ctx, resource)
+			})
+			r := &VllmDeploymentReconciler{Client: k8sClient, Scheme: k8sClient.Scheme(), recorder: &record.FakeRecorder{}}
+			request := reconcile.Request{NamespacedName: client.ObjectKeyFromObject(resource)}
+			deploymentKey := types.NamespacedName{Name: "paused-deployment", Namespace: "default"}
+			_, err := r.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+
+			By("Pausing with the annotation and pinning the image by hand")
+			Expect(k8sClient.Get(ctx, request.NamespacedName, resource)).To(Succeed())
+			resource.Annotations = map[string]string{pausedAnnotation: "true"}
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			deployment := &appsv1.Deployment{}
+			Expect(k8sClient.Get(ctx, deploymentKey, deployment)).To(Succeed())
+			deployment.Spec.Template.Spec.Containers[0].Image = "vllm/vllm-openai:v0.6.1"
+			Expect(k8sClient.Update(ctx, deployment)).To(Succeed())
+
+			recorder := record.NewFakeRecorder(10)
+			r.recorder = recorder
+			_, err = r.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, deploymentKey, deployment)).To(Succeed())
+			Expect(deployment.Spec.Template.Spec.Containers[0].Image).To(Equal("vllm/vllm-openai:v0.6.1"))
+			Expect(k8sClient.Get(ctx, request.NamespacedName, resource)).To(Succeed())
+			Expect(meta.FindStatusCondition(resource.Status.Conditions, corev1alpha1.ConditionPaused)).To(
+				HaveField("Reason", corev1alpha1.ReasonPausedByAnnotation))
+			Expect(resource.Status.Phase).To(Equal(corev1alpha1.PhasePaused))
+			Expect(resource.Status.Image).To(Equal("vllm/vllm-openai:v0.6.1"))
+			Expect(recorder.Events).To(Receive(HavePrefix("Normal Paused ")))
+
+			By("Resuming")
+			delete(resource.Annotations, pausedAnnotation)
+			Expect(k8sClient.Update(ctx, resource)).To(Succeed())
+			_, err = r.Reconcile(ctx, request)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(k8sClient.Get(ctx, deploymentKey, deployment)).To(Succeed())
+			Expect(deployment.Spec.Template.Spec.Containers[0].Image).To(Equal("vllm/vllm-openai:v0.6.2"))
+			Expect(k8sClient.Get(ctx, request.NamespacedName, resource)).To(Succeed())
+			Expect(meta.IsStatusConditionFalse(resource.Status.Conditions, corev1alpha1.ConditionPaused)).To(BeTrue())
+			Expect(recorder.Events).To(Receive(Equal("Normal Updated Updated Deployment paused-deployment")))
+			Expect(recorder.Events).To(Receive(Equal("Normal Resumed Reconciliation resumed")))
+		})
+	})
+
+	Context("When diffing an updated object", func() {
+		It("should list the changed fields down to the leaves", func() {
+			existing := &appsv1.Deployment{
+				ObjectMeta: metav1.ObjectMeta{Name: "diff", ResourceVersion: "7"},
+				Spec: appsv1.DeploymentSpec{
+					Replicas: ptr.To(int32(1)),
+					Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{Containers: []corev1.Container{
+						{Name: "vllm", Image: "vllm/vllm-openai:v0.6.2", Args: []string{"--port", "8000"}},
+					}}},
+				},
+				Status: appsv1.DeploymentStatus{Replicas: 1},
+			}
+			updated := existing.DeepCopy()
+			updated.ResourceVersion = "8"
+			updated.Status.Replicas = 2
+			updated.Spec.Replicas = ptr.To(int32(2))
+			updated.Spec.Template.Spec.Containers[0].Image = "vllm/vllm-openai:v0.6.3"
+			updated.Spec.Template.Spec.Containers[0].Args = append(updated.Spec.Template.Spec.Containers[0].Args, "--enforce-eager")
+
+			changes, err := diffObjects(existing, updated)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(changes).To(Equal([]fieldChange{
+				{Path: ".spec.replicas", Old: int64(1), New: int64(2)},
+				{
+					Path: ".spec.template.spec.containers[0].args",
+					Old:  []any{"--port", "8000"},
+					New:  []any{"--port", "8000", "--enforce-eager"},
+				},
+				{Path: ".spec.template.spec.containers[0].image", Old: "vllm/vllm-openai:v0.6.2", New: "vllm/vllm-openai:v0.6.3"},
+			}))
+		})
 	})
 })
+
+// deleteAndFinalize deletes the given vllmDeployment and runs the reconciler
+// once to remove its finalizer, as no controller runs in the test environment.
+func deleteAndFinalize(ctx context.Context, resource *corev1alpha1.VllmDeployment) {
+	Expect(k8sClient.Delete(ctx, resource)).To(Succeed())
+	controllerReconciler := &VllmDeploymentReconciler{
+		Client:   k8sClient,
+		Scheme:   k8sClient.Scheme(),
+		recorder: &record.FakeRecorder{},
+	}
+	_, err := controllerReconciler.Reconcile(ctx, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(resource)})
+	Expect(err).NotTo(HaveOccurred())
+	Expect(errors.IsNotFound(k8sClient.Get(ctx, client.ObjectKeyFromObject(resource), &corev1alpha1.VllmDeployment{}))).To(BeTrue())
+}
+
+// stubScraper returns the same metrics, or error, for every pod.
+type stubScraper struct {
+	metrics map[string]float64
+	err     error
+}
+
+func (s *stubScraper) scrape(context.Context, *corev1.Pod, int32) (map[string]float64, error) {
+	return s.metrics, s.err
+}
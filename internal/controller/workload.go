@@ -0,0 +1,272 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/apiutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	vllm "github.com/revving-ai/vLLM-k8s-operator/api/v1alpha1"
+)
+
+// workload is a kind of object running the vLLM pods of a vllmDeployment.
+type workload interface {
+	// desired constructs the objects backing the given vllmDeployment.
+	desired(v *vllm.VllmDeployment) ([]client.Object, error)
+	// setStatus fills the replica counts and the Ready, Available,
+	// Progressing, Degraded and ModelLoaded conditions from the objects read
+	// from the cluster and the pods of the vllmDeployment.
+	setStatus(status *vllm.VllmDeploymentStatus, v *vllm.VllmDeployment, objects []client.Object, pods []corev1.Pod)
+}
+
+// workloadKind returns the kind of object running the vLLM pods of the given
+// vllmDeployment, defaulting multi-node groups to a LeaderWorkerSet when the
+// API is available.
+func (r *VllmDeploymentReconciler) workloadKind(v *vllm.VllmDeployment) vllm.WorkloadKind {
+	switch {
+	case v.Spec.WorkloadKind != "":
+		return v.Spec.WorkloadKind
+	case isMultiNode(&v.Spec) && r.hasLeaderWorkerSetAPI():
+		return vllm.WorkloadKindLeaderWorkerSet
+	case isMultiNode(&v.Spec):
+		return vllm.WorkloadKindStatefulSet
+	}
+	return vllm.WorkloadKindDeployment
+}
+
+// workloadFor returns the implementation of the given workload kind.
+func (r *VllmDeploymentReconciler) workloadFor(kind vllm.WorkloadKind) (workload, error) {
+	switch kind {
+	case vllm.WorkloadKindDeployment:
+		return deploymentWorkload{}, nil
+	case vllm.WorkloadKindStatefulSet:
+		return statefulSetWorkload{}, nil
+	case vllm.WorkloadKindLeaderWorkerSet:
+		if !r.hasLeaderWorkerSetAPI() {
+			return nil, fmt.Errorf("the %s CRD is not installed", leaderWorkerSetGVK.GroupKind())
+		}
+		return leaderWorkerSetWorkload{}, nil
+	}
+	return nil, fmt.Errorf("unsupported workload kind %q", kind)
+}
+
+// desiredReplicas returns the number of replicas of the given vllmDeployment,
+// zero included, the lower limit of the autoscaler when it manages them and
+// zero while spec.idle scales it to zero.
+func desiredReplicas(v *vllm.VllmDeployment) int32 {
+	if isScaledToZero(v) {
+		return 0
+	}
+	if isAutoscaled(&v.Spec) {
+		return minReplicas(&v.Spec)
+	}
+	if v.Spec.Replicas != nil {
+		return *v.Spec.Replicas
+	}
+	return vllm.DefaultReplicas
+}
+
+// reconcileWorkload applies the objects of the given workload and returns
+// them as read from the cluster, then deletes the objects left over
+// by another workload kind.
+func (r *VllmDeploymentReconciler) reconcileWorkload(ctx context.Context, v *vllm.VllmDeployment, w workload) ([]client.Object, error) {
+	desired, err := w.desired(v)
+	if err != nil {
+		return nil, err
+	}
+	configHash, err := r.configHash(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+	if configHash != "" {
+		for _, obj := range desired {
+			setPodTemplateAnnotation(obj, configHashAnnotation, configHash)
+		}
+	}
+
+	type scaling struct {
+		obj      client.Object
+		from, to int32
+	}
+	var scalings []scaling
+	objects, err := r.applyObjects(ctx, v, desired, func(existing, desired client.Object) error {
+		// the governing Service of a StatefulSet is immutable, one created
+		// before it was headless keeps its own until recreated
+		if sts, ok := existing.(*appsv1.StatefulSet); ok {
+			desired.(*appsv1.StatefulSet).Spec.ServiceName = sts.Spec.ServiceName
+		}
+		// the autoscaler owns the replicas, unless the workload is scaled to
+		// zero or woken up by spec.idle
+		if isAutoscaled(&v.Spec) && !isScaledToZero(v) && workloadReplicas(existing) > 0 {
+			omitReplicas(desired)
+			return r.handOverReplicas(ctx, existing)
+		}
+		if from, to := workloadReplicas(existing), workloadReplicas(desired); from != to {
+			scalings = append(scalings, scaling{desired, from, to})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range scalings {
+		reason := "spec.replicas changed"
+		switch {
+		case isScaledToZero(v):
+			reason = "idle"
+		case isAutoscaled(&v.Spec):
+			reason = "autoscaling minReplicas"
+		}
+		r.recordScaling(v, s.obj, s.from, s.to, reason)
+	}
+
+	if err := r.deleteStaleWorkloadObjects(ctx, v, desired); err != nil {
+		return nil, err
+	}
+	return objects, nil
+}
+
+// applyObjects applies the given objects owned by the given vllmDeployment,
+// adapting each to the existing one with adjust when not nil, and returns
+// them as read from the cluster.
+func (r *VllmDeploymentReconciler) applyObjects(ctx context.Context, v *vllm.VllmDeployment, desired []client.Object,
+	adjust func(existing, desired client.Object) error) ([]client.Object, error) {
+	for _, obj := range desired {
+		if err := r.apply(ctx, v, obj, adjust); err != nil {
+			return nil, err
+		}
+	}
+	return desired, nil
+}
+
+// newObjectOfKind returns an empty object of the same kind as obj to read the
+// existing one into.
+func newObjectOfKind(obj client.Object, gvk schema.GroupVersionKind) client.Object {
+	if _, ok := obj.(*unstructured.Unstructured); ok {
+		u := &unstructured.Unstructured{}
+		u.SetGroupVersionKind(gvk)
+		return u
+	}
+	return reflect.New(reflect.TypeOf(obj).Elem()).Interface().(client.Object)
+}
+
+// workloadObjects returns an empty object for everything any workload kind
+// may create for the given vllmDeployment.
+func workloadObjects(v *vllm.VllmDeployment) []client.Object {
+	lws := &unstructured.Unstructured{}
+	lws.SetGroupVersionKind(leaderWorkerSetGVK)
+	lws.SetName(leaderWorkerSetName(v))
+
+	objects := []client.Object{
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: deploymentName(v)}},
+		&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: statefulSetName(v)}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: statefulSetServiceName(v)}},
+		&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: leaderName(v)}},
+		&appsv1.StatefulSet{ObjectMeta: metav1.ObjectMeta{Name: workerName(v)}},
+		&corev1.Service{ObjectMeta: metav1.ObjectMeta{Name: leaderName(v)}},
+		lws,
+	}
+	for _, obj := range objects {
+		obj.SetNamespace(v.Namespace)
+	}
+	return objects
+}
+
+// deleteStaleWorkloadObjects deletes the objects controlled by the given
+// vllmDeployment that another workload kind, or the same kind with or without
+// multiNode, created.
+func (r *VllmDeploymentReconciler) deleteStaleWorkloadObjects(ctx context.Context, v *vllm.VllmDeployment, desired []client.Object) error {
+	wanted := map[string]bool{}
+	for _, obj := range desired {
+		gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+		if err != nil {
+			return err
+		}
+		wanted[gvk.Kind+"/"+obj.GetName()] = true
+	}
+
+	var stale []client.Object
+	for _, obj := range workloadObjects(v) {
+		gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+		if err != nil {
+			return err
+		}
+		if !wanted[gvk.Kind+"/"+obj.GetName()] {
+			stale = append(stale, obj)
+		}
+	}
+	_, err := r.deleteOwnedObjects(ctx, v, stale...)
+	return err
+}
+
+// deleteOwnedObjects deletes the given objects, when they exist, are
+// controlled by the given vllmDeployment and are not already being deleted.
+// It reports whether it deleted any of them.
+func (r *VllmDeploymentReconciler) deleteOwnedObjects(ctx context.Context, v *vllm.VllmDeployment, objects ...client.Object) (bool, error) {
+	log := log.FromContext(ctx)
+
+	deleted := false
+	for _, obj := range objects {
+		gvk, err := apiutil.GVKForObject(obj, r.Scheme)
+		if err != nil {
+			return deleted, err
+		}
+		if err := r.Get(ctx, client.ObjectKeyFromObject(obj), obj); err != nil {
+			// the LeaderWorkerSet kind is unknown when its CRD is not installed
+			if apierrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+				continue
+			}
+			return deleted, err
+		}
+		if !metav1.IsControlledBy(obj, v) || !obj.GetDeletionTimestamp().IsZero() {
+			continue
+		}
+		log.Info("Deleting "+gvk.Kind, gvk.Kind+".Name", obj.GetName())
+		if err := r.Delete(ctx, obj, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			return deleted, err
+		}
+		deleted = true
+	}
+	return deleted, nil
+}
+
+// deploymentWorkload runs the vLLM pods with a Deployment, the default.
+type deploymentWorkload struct{}
+
+func (deploymentWorkload) desired(v *vllm.VllmDeployment) ([]client.Object, error) {
+	return []client.Object{constructDeployment(v)}, nil
+}
+
+func (deploymentWorkload) setStatus(status *vllm.VllmDeploymentStatus, v *vllm.VllmDeployment, objects []client.Object, _ []corev1.Pod) {
+	d := objects[0].(*appsv1.Deployment)
+	status.Replicas = d.Status.Replicas
+	status.ReadyReplicas = d.Status.ReadyReplicas
+	status.UpdatedReplicas = d.Status.UpdatedReplicas
+	setDeploymentConditions(status, v.Generation, d)
+}
@@ -0,0 +1,695 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	vllm "github.com/revving-ai/vLLM-k8s-operator/api/v1alpha1"
+)
+
+var (
+	supportedDtypes        = []string{"auto", "half", "float16", "bfloat16", "float", "float32"}
+	supportedKVCacheDtypes = []string{"auto", "fp8", "fp8_e4m3", "fp8_e5m2"}
+	quantizationPattern    = regexp.MustCompile(`^[a-z0-9_-]+$`)
+
+	// operatorOwnedFlags are the vLLM flags rendered by the operator, either
+	// unconditionally or from a typed field, which extraArgs must not repeat.
+	operatorOwnedFlags = []string{
+		"--model", "--port", "--download-dir",
+		"--gpu-memory-utilization", "--uvicorn-log-level", "--block-size", "--max-model-len", "--enforce-eager",
+		"--tensor-parallel-size", "--pipeline-parallel-size", "--dtype", "--quantization", "--kv-cache-dtype",
+		"--max-num-seqs", "--max-num-batched-tokens", "--enable-prefix-caching", "--enable-chunked-prefill",
+		"--swap-space", "--served-model-name", "--trust-remote-code", "--seed", "--revision",
+		"--distributed-executor-backend",
+	}
+)
+
+// normalizeFlag returns the long form of the given flag name without its
+// value, e.g. "--max_log_len=100" becomes "--max-log-len" as vLLM accepts both.
+func normalizeFlag(flag string) string {
+	name, _, _ := strings.Cut(strings.TrimLeft(flag, "-"), "=")
+	return "--" + strings.ReplaceAll(name, "_", "-")
+}
+
+// nolint:unused
+// log is for logging in this package.
+var vllmdeploymentlog = logf.Log.WithName("vllmdeployment-resource")
+
+// SetupVllmDeploymentWebhookWithManager registers the webhook for VllmDeployment in the manager.
+func SetupVllmDeploymentWebhookWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&vllm.VllmDeployment{}).
+		WithValidator(&VllmDeploymentCustomValidator{}).
+		WithDefaulter(&VllmDeploymentCustomDefaulter{}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/mutate-core-vllmoperator-org-v1alpha1-vllmdeployment,mutating=true,failurePolicy=fail,sideEffects=None,groups=core.vllmoperator.org,resources=vllmdeployments,verbs=create;update,versions=v1alpha1,name=mvllmdeployment-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// VllmDeploymentCustomDefaulter sets default values on the VllmDeployment
+// resource when it is created or updated.
+type VllmDeploymentCustomDefaulter struct{}
+
+var _ webhook.CustomDefaulter = &VllmDeploymentCustomDefaulter{}
+
+// Default implements webhook.CustomDefaulter so a webhook will be registered for the Kind VllmDeployment.
+func (d *VllmDeploymentCustomDefaulter) Default(ctx context.Context, obj runtime.Object) error {
+	vllmdeployment, ok := obj.(*vllm.VllmDeployment)
+	if !ok {
+		return fmt.Errorf("expected a VllmDeployment object but got %T", obj)
+	}
+	vllmdeploymentlog.Info("Defaulting for VllmDeployment", "name", vllmdeployment.GetName())
+
+	defaultVllmDeploymentSpec(&vllmdeployment.Spec)
+	return nil
+}
+
+// defaultVllmDeploymentSpec fills in the fields of the spec that have a sensible default.
+func defaultVllmDeploymentSpec(spec *vllm.VllmDeploymentSpec) {
+	if spec.Replicas == nil {
+		replicas := vllm.DefaultReplicas
+		spec.Replicas = &replicas
+	}
+
+	if spec.VLLMConfig != nil && spec.VLLMConfig.Port == 0 {
+		spec.VLLMConfig.Port = vllm.DefaultVllmPort
+	}
+
+	if spec.GPU != nil {
+		if spec.GPU.ResourceName == "" {
+			spec.GPU.ResourceName = vllm.DefaultGPUResourceName
+		}
+		if spec.GPU.Product != "" && spec.GPU.ProductLabel == "" {
+			spec.GPU.ProductLabel = vllm.DefaultGPUProductLabel
+		}
+	}
+
+	if len(spec.Containers) == 1 && spec.Containers[0].Name == "" {
+		spec.Containers[0].Name = vllm.DefaultVllmContainerName
+	}
+	if i := vllmContainerIndex(spec); i >= 0 && spec.Containers[i].Image == "" {
+		spec.Containers[i].Image = vllm.DefaultVllmImage
+	}
+}
+
+// vllmContainerIndex returns the index of the container running vLLM: the only
+// container, or the one named "vllm" when there are several. It returns -1
+// when no such container exists.
+func vllmContainerIndex(spec *vllm.VllmDeploymentSpec) int {
+	if len(spec.Containers) == 1 {
+		return 0
+	}
+	for i := range spec.Containers {
+		if spec.Containers[i].Name == vllm.DefaultVllmContainerName {
+			return i
+		}
+	}
+	return -1
+}
+
+// +kubebuilder:webhook:path=/validate-core-vllmoperator-org-v1alpha1-vllmdeployment,mutating=false,failurePolicy=fail,sideEffects=None,groups=core.vllmoperator.org,resources=vllmdeployments,verbs=create;update,versions=v1alpha1,name=vvllmdeployment-v1alpha1.kb.io,admissionReviewVersions=v1
+
+// VllmDeploymentCustomValidator validates the VllmDeployment resource when it
+// is created or updated.
+type VllmDeploymentCustomValidator struct{}
+
+var _ webhook.CustomValidator = &VllmDeploymentCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type VllmDeployment.
+func (v *VllmDeploymentCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	vllmdeployment, ok := obj.(*vllm.VllmDeployment)
+	if !ok {
+		return nil, fmt.Errorf("expected a VllmDeployment object but got %T", obj)
+	}
+	vllmdeploymentlog.Info("Validation for VllmDeployment upon creation", "name", vllmdeployment.GetName())
+
+	return nil, validateVllmDeployment(vllmdeployment)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type VllmDeployment.
+func (v *VllmDeploymentCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	vllmdeployment, ok := newObj.(*vllm.VllmDeployment)
+	if !ok {
+		return nil, fmt.Errorf("expected a VllmDeployment object for the newObj but got %T", newObj)
+	}
+	vllmdeploymentlog.Info("Validation for VllmDeployment upon update", "name", vllmdeployment.GetName())
+
+	return nil, validateVllmDeployment(vllmdeployment)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type VllmDeployment.
+func (v *VllmDeploymentCustomValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validateVllmDeployment returns an Invalid error listing every problem found
+// in the spec of the given VllmDeployment, or nil if it is valid.
+func validateVllmDeployment(v *vllm.VllmDeployment) error {
+	allErrs := validateVllmDeploymentSpec(&v.Spec, field.NewPath("spec"))
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(vllm.GroupVersion.WithKind("VllmDeployment").GroupKind(), v.Name, allErrs)
+}
+
+func validateVllmDeploymentSpec(spec *vllm.VllmDeploymentSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if spec.Replicas != nil && *spec.Replicas < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("replicas"), *spec.Replicas, "must be greater than or equal to 0"))
+	}
+	allErrs = append(allErrs, validateModel(spec.Model, fldPath.Child("model"))...)
+	allErrs = append(allErrs, validateVLLMConfig(spec.VLLMConfig, fldPath.Child("vLLMConfig"))...)
+	allErrs = append(allErrs, validateContainers(spec, fldPath.Child("containers"))...)
+	allErrs = append(allErrs, validateInitContainers(spec, fldPath.Child("initContainers"))...)
+	allErrs = append(allErrs, validateGPU(spec, fldPath.Child("gpu"))...)
+	allErrs = append(allErrs, validateMultiNode(spec, fldPath.Child("multiNode"))...)
+	allErrs = append(allErrs, validateProbes(spec.Probes, fldPath.Child("probes"))...)
+	allErrs = append(allErrs, validateAutoscaling(spec, fldPath.Child("autoscaling"))...)
+	allErrs = append(allErrs, validateIdle(spec.Idle, fldPath.Child("idle"))...)
+
+	return allErrs
+}
+
+func validateModel(model *vllm.ModelConfig, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if model == nil {
+		return append(allErrs, field.Required(fldPath, "model must be set"))
+	}
+	if model.Name == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("name"), "model name must be set"))
+	}
+	if cache := model.Cache; cache != nil {
+		if cache.ExistingClaim == "" && cache.Size == nil {
+			allErrs = append(allErrs, field.Required(fldPath.Child("cache", "size"), "size must be set when existingClaim is empty"))
+		}
+		if cache.Size != nil && cache.Size.Sign() <= 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("cache", "size"), cache.Size.String(), "must be greater than 0"))
+		}
+	}
+	allErrs = append(allErrs, validateModelSource(model, fldPath)...)
+
+	return allErrs
+}
+
+func validateModelSource(model *vllm.ModelConfig, modelPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	fldPath := modelPath.Child("source")
+
+	source := model.Source
+	if source == nil {
+		return allErrs
+	}
+	sourceType := source.Type
+	if sourceType == "" {
+		sourceType = vllm.ModelSourceHuggingFace
+	}
+
+	// exactly the member matching type must be set
+	members := []struct {
+		name  string
+		typ   vllm.ModelSourceType
+		isSet bool
+	}{
+		{"s3", vllm.ModelSourceS3, source.S3 != nil},
+		{"oci", vllm.ModelSourceOCI, source.OCI != nil},
+		{"pvc", vllm.ModelSourcePVC, source.PVC != nil},
+		{"hostPath", vllm.ModelSourceHostPath, source.HostPath != nil},
+	}
+	for _, m := range members {
+		switch {
+		case m.typ == sourceType && !m.isSet:
+			allErrs = append(allErrs, field.Required(fldPath.Child(m.name), fmt.Sprintf("must be set when type is %q", sourceType)))
+		case m.typ != sourceType && m.isSet:
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child(m.name), fmt.Sprintf("must not be set when type is %q", sourceType)))
+		}
+	}
+
+	if s3 := source.S3; s3 != nil && !strings.HasPrefix(s3.URI, "s3://") {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("s3", "uri"), s3.URI, "must start with s3://"))
+	}
+	if oci := source.OCI; oci != nil && oci.Reference == "" {
+		allErrs = append(allErrs, field.Required(fldPath.Child("oci", "reference"), "reference must be set"))
+	}
+	if pvc := source.PVC; pvc != nil {
+		if pvc.ClaimName == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("pvc", "claimName"), "claimName must be set"))
+		}
+		if path.IsAbs(pvc.Path) || strings.HasPrefix(path.Clean(pvc.Path), "..") {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("pvc", "path"), pvc.Path, "must be a relative path inside the volume"))
+		}
+	}
+	if hostPath := source.HostPath; hostPath != nil && !path.IsAbs(hostPath.Path) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("hostPath", "path"), hostPath.Path, "must be an absolute path"))
+	}
+
+	if sourceType != vllm.ModelSourceHuggingFace {
+		if model.TokenSecretRef != nil {
+			allErrs = append(allErrs, field.Forbidden(modelPath.Child("tokenSecretRef"), "only supported with the huggingface source"))
+		}
+		if model.Cache != nil && model.Cache.DownloaderImage != "" {
+			allErrs = append(allErrs, field.Forbidden(modelPath.Child("cache", "downloaderImage"), "only supported with the huggingface source, use the fetcherImage of the source instead"))
+		}
+	}
+	if (sourceType == vllm.ModelSourcePVC || sourceType == vllm.ModelSourceHostPath) && model.Cache != nil {
+		allErrs = append(allErrs, field.Forbidden(modelPath.Child("cache"), fmt.Sprintf("the weights are read in place when type is %q", sourceType)))
+	}
+
+	return allErrs
+}
+
+func validateVLLMConfig(vc *vllm.VLLMConfig, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if vc == nil {
+		return append(allErrs, field.Required(fldPath, "vLLMConfig must be set"))
+	}
+	if vc.Port < 1 || vc.Port > 65535 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("port"), vc.Port, "must be between 1 and 65535"))
+	}
+	if vc.GpuMemoryUtilization != "" {
+		utilization, err := strconv.ParseFloat(vc.GpuMemoryUtilization, 64)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("gpu-memory-utilization"), vc.GpuMemoryUtilization, "must be a number"))
+		} else if utilization <= 0 || utilization > 1 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("gpu-memory-utilization"), vc.GpuMemoryUtilization, "must be greater than 0 and at most 1"))
+		}
+	}
+	if vc.BlockSize < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("block-size"), vc.BlockSize, "must be greater than or equal to 0"))
+	}
+	if vc.MaxModelLen < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("max-model-len"), vc.MaxModelLen, "must be greater than or equal to 0"))
+	}
+	for _, f := range []struct {
+		name  string
+		value int
+	}{
+		{"tensor-parallel-size", vc.TensorParallelSize},
+		{"pipeline-parallel-size", vc.PipelineParallelSize},
+		{"max-num-seqs", vc.MaxNumSeqs},
+		{"max-num-batched-tokens", vc.MaxNumBatchedTokens},
+	} {
+		if f.value < 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child(f.name), f.value, "must be greater than or equal to 0"))
+		}
+	}
+	if vc.Dtype != "" && !slices.Contains(supportedDtypes, vc.Dtype) {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("dtype"), vc.Dtype, supportedDtypes))
+	}
+	if vc.KVCacheDtype != "" && !slices.Contains(supportedKVCacheDtypes, vc.KVCacheDtype) {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("kv-cache-dtype"), vc.KVCacheDtype, supportedKVCacheDtypes))
+	}
+	if vc.Quantization != "" && !quantizationPattern.MatchString(vc.Quantization) {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("quantization"), vc.Quantization, "must consist of lower case alphanumeric characters, '-' or '_'"))
+	}
+	if vc.SwapSpace != "" {
+		swapSpace, err := strconv.ParseFloat(vc.SwapSpace, 64)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("swap-space"), vc.SwapSpace, "must be a number"))
+		} else if swapSpace < 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("swap-space"), vc.SwapSpace, "must be greater than or equal to 0"))
+		}
+	}
+	for i, name := range vc.ServedModelName {
+		if strings.TrimSpace(name) == "" {
+			allErrs = append(allErrs, field.Required(fldPath.Child("served-model-name").Index(i), "served model name must not be empty"))
+		}
+	}
+	if vc.Seed != nil && *vc.Seed < 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("seed"), *vc.Seed, "must be greater than or equal to 0"))
+	}
+	allErrs = append(allErrs, validateExtraArgs(vc, fldPath)...)
+	// vLLM refuses to start when a batch cannot hold one sequence per slot, or
+	// a whole prompt unless it is chunked
+	if vc.MaxNumBatchedTokens > 0 && vc.MaxNumSeqs > vc.MaxNumBatchedTokens {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("max-num-batched-tokens"), vc.MaxNumBatchedTokens,
+			fmt.Sprintf("must be greater than or equal to max-num-seqs (%d)", vc.MaxNumSeqs)))
+	}
+	if vc.MaxNumBatchedTokens > 0 && !vc.EnableChunkedPrefill && vc.MaxModelLen > vc.MaxNumBatchedTokens {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("max-num-batched-tokens"), vc.MaxNumBatchedTokens,
+			fmt.Sprintf("must be greater than or equal to max-model-len (%d) unless enable-chunked-prefill is set", vc.MaxModelLen)))
+	}
+
+	return allErrs
+}
+
+func validateExtraArgs(vc *vllm.VLLMConfig, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	flags := make(map[string]bool, len(vc.ExtraArgs)+len(vc.ExtraArgsMap))
+	for i, arg := range vc.ExtraArgs {
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		flag := normalizeFlag(arg)
+		if slices.Contains(operatorOwnedFlags, flag) {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("extraArgs").Index(i),
+				fmt.Sprintf("%s is set by the operator, use the matching field instead", flag)))
+		}
+		flags[flag] = true
+	}
+
+	names := make([]string, 0, len(vc.ExtraArgsMap))
+	for name := range vc.ExtraArgsMap {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		keyPath := fldPath.Child("extraArgsMap").Key(name)
+		if strings.Trim(name, "-") == "" {
+			allErrs = append(allErrs, field.Invalid(keyPath, name, "must be a flag name"))
+			continue
+		}
+		flag := normalizeFlag(name)
+		switch {
+		case slices.Contains(operatorOwnedFlags, flag):
+			allErrs = append(allErrs, field.Forbidden(keyPath,
+				fmt.Sprintf("%s is set by the operator, use the matching field instead", flag)))
+		case flags[flag]:
+			allErrs = append(allErrs, field.Duplicate(keyPath, flag))
+		}
+		flags[flag] = true
+	}
+
+	return allErrs
+}
+
+func validateGPU(spec *vllm.VllmDeploymentSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	gpu := spec.GPU
+	if gpu == nil {
+		return allErrs
+	}
+	if gpu.Count < 1 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("count"), gpu.Count, "must be greater than or equal to 1"))
+		return allErrs
+	}
+	if gpu.SharedMemorySize != nil && gpu.SharedMemorySize.Sign() <= 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("sharedMemorySize"), gpu.SharedMemorySize.String(), "must be greater than 0"))
+	}
+	if spec.VLLMConfig == nil {
+		return allErrs
+	}
+	if tp := spec.VLLMConfig.TensorParallelSize; tp > 0 && int(gpu.Count)%tp != 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "vLLMConfig", "tensor-parallel-size"), tp,
+			fmt.Sprintf("must divide gpu.count (%d)", gpu.Count)))
+	}
+
+	return allErrs
+}
+
+func validateMultiNode(spec *vllm.VllmDeploymentSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	mn := spec.MultiNode
+	if mn == nil {
+		return allErrs
+	}
+	if mn.Size < 2 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("size"), mn.Size, "must be greater than or equal to 2"))
+		return allErrs
+	}
+	if spec.WorkloadKind == vllm.WorkloadKindDeployment {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "workloadKind"), spec.WorkloadKind,
+			"must be StatefulSet or LeaderWorkerSet with multiNode"))
+	}
+	if mn.RayPort < 0 || mn.RayPort > 65535 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("rayPort"), mn.RayPort, "must be between 1 and 65535"))
+	}
+	if spec.VLLMConfig != nil && mn.RayPort != 0 && int(mn.RayPort) == spec.VLLMConfig.Port {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("rayPort"), mn.RayPort, "must differ from vLLMConfig.port"))
+	}
+
+	// every GPU of the group runs one shard of the model
+	if spec.GPU == nil || spec.VLLMConfig == nil {
+		return allErrs
+	}
+	tp, pp := spec.VLLMConfig.TensorParallelSize, spec.VLLMConfig.PipelineParallelSize
+	if tp == 0 && pp == 0 {
+		return allErrs
+	}
+	if tp == 0 {
+		tp = int(spec.GPU.Count)
+	}
+	if pp == 0 {
+		pp = int(mn.Size)
+	}
+	if total := int(mn.Size * spec.GPU.Count); tp*pp != total {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "vLLMConfig", "pipeline-parallel-size"), pp,
+			fmt.Sprintf("tensor-parallel-size (%d) times pipeline-parallel-size must equal the GPUs of a group (%d)", tp, total)))
+	}
+
+	return allErrs
+}
+
+func validateAutoscaling(spec *vllm.VllmDeploymentSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	as := spec.Autoscaling
+	if as == nil {
+		return allErrs
+	}
+	minReplicas := int32(1)
+	if as.MinReplicas != nil {
+		minReplicas = *as.MinReplicas
+		if minReplicas < 1 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child("minReplicas"), minReplicas, "must be greater than or equal to 1"))
+		}
+	}
+	if as.MaxReplicas < minReplicas {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("maxReplicas"), as.MaxReplicas,
+			fmt.Sprintf("must be greater than or equal to minReplicas (%d)", minReplicas)))
+	}
+	if as.MetricType != "" && as.MetricType != vllm.AutoscalingMetricTypePods && as.MetricType != vllm.AutoscalingMetricTypeExternal {
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("metricType"), as.MetricType,
+			[]string{string(vllm.AutoscalingMetricTypePods), string(vllm.AutoscalingMetricTypeExternal)}))
+	}
+
+	targets := []struct {
+		name   string
+		target *vllm.MetricTarget
+	}{
+		{"numRequestsWaiting", as.NumRequestsWaiting},
+		{"numRequestsRunning", as.NumRequestsRunning},
+		{"gpuCacheUsage", as.GPUCacheUsage},
+		{"tokensPerSecond", as.TokensPerSecond},
+	}
+	var hasTarget bool
+	for _, t := range targets {
+		if t.target == nil {
+			continue
+		}
+		hasTarget = true
+		if t.target.AverageValue.Sign() <= 0 {
+			allErrs = append(allErrs, field.Invalid(fldPath.Child(t.name, "averageValue"), t.target.AverageValue.String(), "must be greater than 0"))
+		}
+	}
+	if !hasTarget {
+		allErrs = append(allErrs, field.Required(fldPath, "at least one of numRequestsWaiting, numRequestsRunning, gpuCacheUsage or tokensPerSecond must be set"))
+	}
+	if as.GPUCacheUsage != nil && as.GPUCacheUsage.AverageValue.Cmp(resource.MustParse("1")) > 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("gpuCacheUsage", "averageValue"), as.GPUCacheUsage.AverageValue.String(),
+			"must be a fraction of the KV cache, at most 1"))
+	}
+
+	allErrs = append(allErrs, validateAutoscalingProvider(as, fldPath)...)
+
+	// the leader and worker StatefulSets of multi-node groups cannot be scaled as one object
+	if spec.MultiNode != nil && spec.WorkloadKind == vllm.WorkloadKindStatefulSet {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("spec", "workloadKind"), spec.WorkloadKind,
+			"must be LeaderWorkerSet to autoscale multiNode groups"))
+	}
+
+	return allErrs
+}
+
+func validateAutoscalingProvider(as *vllm.AutoscalingConfig, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	switch as.Provider {
+	case "", vllm.AutoscalingProviderHPA:
+		if as.Operator != nil {
+			allErrs = append(allErrs, field.Forbidden(fldPath.Child("operator"), "may only be set with the Operator provider"))
+		}
+		return allErrs
+	case vllm.AutoscalingProviderOperator:
+	default:
+		allErrs = append(allErrs, field.NotSupported(fldPath.Child("provider"), as.Provider,
+			[]string{string(vllm.AutoscalingProviderHPA), string(vllm.AutoscalingProviderOperator)}))
+		return allErrs
+	}
+
+	// the operator scrapes the gauges of the pods, rates and external metrics need an adapter
+	if as.TokensPerSecond != nil {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("tokensPerSecond"), "is not supported by the Operator provider"))
+	}
+	if as.MetricType == vllm.AutoscalingMetricTypeExternal {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("metricType"), "is not supported by the Operator provider"))
+	}
+	if as.Behavior != nil {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Child("behavior"), "is only used by the HPA provider, use operator instead"))
+	}
+
+	if as.Operator == nil {
+		return allErrs
+	}
+	operatorPath := fldPath.Child("operator")
+	if d := as.Operator.SyncPeriod; d != nil && d.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(operatorPath.Child("syncPeriod"), d.Duration.String(), "must be greater than 0"))
+	}
+	durations := []struct {
+		name     string
+		duration *metav1.Duration
+	}{
+		{"scaleUpStabilizationWindow", as.Operator.ScaleUpStabilizationWindow},
+		{"scaleDownStabilizationWindow", as.Operator.ScaleDownStabilizationWindow},
+		{"scaleUpCooldown", as.Operator.ScaleUpCooldown},
+		{"scaleDownCooldown", as.Operator.ScaleDownCooldown},
+	}
+	for _, d := range durations {
+		if d.duration != nil && d.duration.Duration < 0 {
+			allErrs = append(allErrs, field.Invalid(operatorPath.Child(d.name), d.duration.Duration.String(), "must not be negative"))
+		}
+	}
+
+	return allErrs
+}
+
+func validateIdle(idle *vllm.IdleConfig, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if idle == nil {
+		return allErrs
+	}
+	if idle.After.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("after"), idle.After.Duration.String(), "must be greater than 0"))
+	}
+	if d := idle.ActivationTimeout; d != nil && d.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("activationTimeout"), d.Duration.String(), "must be greater than 0"))
+	}
+
+	return allErrs
+}
+
+func validateProbes(probes *vllm.ProbesConfig, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if probes == nil {
+		return allErrs
+	}
+	// as for containers, only the readiness probe may require several successes
+	if probes.Startup != nil && probes.Startup.SuccessThreshold > 1 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("startup", "successThreshold"), probes.Startup.SuccessThreshold, "must be 1"))
+	}
+	if probes.Liveness != nil && probes.Liveness.SuccessThreshold > 1 {
+		allErrs = append(allErrs, field.Invalid(fldPath.Child("liveness", "successThreshold"), probes.Liveness.SuccessThreshold, "must be 1"))
+	}
+
+	return allErrs
+}
+
+func validateContainers(spec *vllm.VllmDeploymentSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	if len(spec.Containers) == 0 {
+		return append(allErrs, field.Required(fldPath, "at least one container running vLLM must be set"))
+	}
+	i := vllmContainerIndex(spec)
+	if i < 0 {
+		return append(allErrs, field.Required(fldPath, fmt.Sprintf("a container named %q must be set when there is more than one container", vllm.DefaultVllmContainerName)))
+	}
+
+	names := make(map[string]bool, len(spec.Containers))
+	for j, c := range spec.Containers {
+		if names[c.Name] {
+			allErrs = append(allErrs, field.Duplicate(fldPath.Index(j).Child("name"), c.Name))
+		}
+		names[c.Name] = true
+	}
+
+	container := spec.Containers[i]
+	// the arguments of vLLM are generated from vLLMConfig
+	if len(container.Args) > 0 {
+		allErrs = append(allErrs, field.Forbidden(fldPath.Index(i).Child("args"),
+			"the vLLM arguments are generated from vLLMConfig, set extra ones in vLLMConfig.extraArgs"))
+	}
+	if spec.VLLMConfig == nil {
+		return allErrs
+	}
+	// the operator sets the serving port, other ports of the container are kept
+	for j, port := range container.Ports {
+		portPath := fldPath.Index(i).Child("ports").Index(j)
+		if port.Name == vllm.ServingPortName && int(port.ContainerPort) != spec.VLLMConfig.Port {
+			allErrs = append(allErrs, field.Invalid(portPath.Child("containerPort"), port.ContainerPort,
+				fmt.Sprintf("must match vLLMConfig.port (%d) for the %q port", spec.VLLMConfig.Port, vllm.ServingPortName)))
+		}
+		if int(port.ContainerPort) == spec.VLLMConfig.Port && port.Name != "" && port.Name != vllm.ServingPortName {
+			allErrs = append(allErrs, field.Invalid(portPath.Child("name"), port.Name,
+				fmt.Sprintf("must be empty or %q for vLLMConfig.port (%d)", vllm.ServingPortName, spec.VLLMConfig.Port)))
+		}
+	}
+
+	return allErrs
+}
+
+func validateInitContainers(spec *vllm.VllmDeploymentSpec, fldPath *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+
+	// container names must be unique across containers, init containers and
+	// the init container the operator adds to fetch the weights
+	names := make(map[string]bool, len(spec.Containers)+1)
+	for _, c := range spec.Containers {
+		names[c.Name] = true
+	}
+	names[vllm.ModelDownloaderContainerName] = true
+	for i, c := range spec.InitContainers {
+		idxPath := fldPath.Index(i)
+		if c.Name == "" {
+			allErrs = append(allErrs, field.Required(idxPath.Child("name"), "init container name must be set"))
+		} else if names[c.Name] {
+			allErrs = append(allErrs, field.Duplicate(idxPath.Child("name"), c.Name))
+		}
+		names[c.Name] = true
+
+		if c.RestartPolicy != nil && *c.RestartPolicy != corev1.ContainerRestartPolicyAlways {
+			allErrs = append(allErrs, field.NotSupported(idxPath.Child("restartPolicy"), *c.RestartPolicy,
+				[]string{string(corev1.ContainerRestartPolicyAlways)}))
+		}
+	}
+
+	return allErrs
+}
@@ -0,0 +1,368 @@
+/*
+Copyright 2024.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+
+	corev1alpha1 "github.com/revving-ai/vLLM-k8s-operator/api/v1alpha1"
+)
+
+var _ = Describe("VllmDeployment Webhook", func() {
+	var (
+		obj       *corev1alpha1.VllmDeployment
+		oldObj    *corev1alpha1.VllmDeployment
+		validator VllmDeploymentCustomValidator
+		defaulter VllmDeploymentCustomDefaulter
+	)
+
+	BeforeEach(func() {
+		obj = &corev1alpha1.VllmDeployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "test-resource", Namespace: "default"},
+			Spec: corev1alpha1.VllmDeploymentSpec{
+				Replicas: ptr.To[int32](1),
+				Model: &corev1alpha1.ModelConfig{
+					Name: "keeeeenw/MicroLlama",
+				},
+				VLLMConfig: &corev1alpha1.VLLMConfig{
+					Port:                 8072,
+					GpuMemoryUtilization: "0.75",
+				},
+				Containers: []corev1.Container{{
+					Name:  "vllm",
+					Image: "vllm/vllm-openai:v0.6.2",
+					Ports: []corev1.ContainerPort{{ContainerPort: 8072}},
+				}},
+			},
+		}
+		oldObj = obj.DeepCopy()
+		validator = VllmDeploymentCustomValidator{}
+		defaulter = VllmDeploymentCustomDefaulter{}
+	})
+
+	Context("When creating VllmDeployment under Defaulting Webhook", func() {
+		It("Should apply defaults when fields are not set", func() {
+			obj.Spec.Replicas = nil
+			obj.Spec.VLLMConfig.Port = 0
+			obj.Spec.Containers[0].Name = ""
+			obj.Spec.Containers[0].Image = ""
+
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec.Replicas).To(HaveValue(Equal(int32(1))))
+			Expect(obj.Spec.VLLMConfig.Port).To(Equal(8000))
+			Expect(obj.Spec.Containers[0].Name).To(Equal("vllm"))
+			Expect(obj.Spec.Containers[0].Image).To(Equal(corev1alpha1.DefaultVllmImage))
+		})
+
+		It("Should default the GPU resource name and product label", func() {
+			obj.Spec.GPU = &corev1alpha1.GPUConfig{Count: 2, Product: "NVIDIA-L4"}
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec.GPU.ResourceName).To(Equal(corev1.ResourceName("nvidia.com/gpu")))
+			Expect(obj.Spec.GPU.ProductLabel).To(Equal("nvidia.com/gpu.product"))
+		})
+
+		It("Should not override fields that are set", func() {
+			Expect(defaulter.Default(ctx, obj)).To(Succeed())
+			Expect(obj.Spec).To(Equal(oldObj.Spec))
+		})
+	})
+
+	Context("When creating or updating VllmDeployment under Validating Webhook", func() {
+		It("Should admit a valid spec", func() {
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny creation without containers", func() {
+			obj.Spec.Containers = nil
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(MatchError(ContainSubstring("spec.containers")))
+		})
+
+		It("Should deny creation without model or vLLMConfig", func() {
+			obj.Spec.Model = nil
+			obj.Spec.VLLMConfig = nil
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.model")))
+			Expect(err).To(MatchError(ContainSubstring("spec.vLLMConfig")))
+		})
+
+		It("Should deny an invalid gpu-memory-utilization", func() {
+			obj.Spec.VLLMConfig.GpuMemoryUtilization = "most"
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(MatchError(ContainSubstring("must be a number")))
+
+			obj.Spec.VLLMConfig.GpuMemoryUtilization = "1.5"
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(MatchError(ContainSubstring("gpu-memory-utilization")))
+		})
+
+		DescribeTable("Should validate the engine arguments",
+			func(configure func(*corev1alpha1.VLLMConfig), errField string) {
+				configure(obj.Spec.VLLMConfig)
+				_, err := validator.ValidateCreate(ctx, obj)
+				if errField == "" {
+					Expect(err).NotTo(HaveOccurred())
+					return
+				}
+				Expect(err).To(MatchError(ContainSubstring("spec.vLLMConfig." + errField)))
+			},
+			Entry("valid tensor-parallel-size", func(vc *corev1alpha1.VLLMConfig) { vc.TensorParallelSize = 2 }, ""),
+			Entry("negative tensor-parallel-size", func(vc *corev1alpha1.VLLMConfig) { vc.TensorParallelSize = -1 }, "tensor-parallel-size"),
+			Entry("valid pipeline-parallel-size", func(vc *corev1alpha1.VLLMConfig) { vc.PipelineParallelSize = 2 }, ""),
+			Entry("negative pipeline-parallel-size", func(vc *corev1alpha1.VLLMConfig) { vc.PipelineParallelSize = -2 }, "pipeline-parallel-size"),
+			Entry("valid dtype", func(vc *corev1alpha1.VLLMConfig) { vc.Dtype = "half" }, ""),
+			Entry("unsupported dtype", func(vc *corev1alpha1.VLLMConfig) { vc.Dtype = "int4" }, "dtype"),
+			Entry("valid quantization", func(vc *corev1alpha1.VLLMConfig) { vc.Quantization = "compressed-tensors" }, ""),
+			Entry("invalid quantization", func(vc *corev1alpha1.VLLMConfig) { vc.Quantization = "AWQ 4bit" }, "quantization"),
+			Entry("valid kv-cache-dtype", func(vc *corev1alpha1.VLLMConfig) { vc.KVCacheDtype = "fp8_e5m2" }, ""),
+			Entry("unsupported kv-cache-dtype", func(vc *corev1alpha1.VLLMConfig) { vc.KVCacheDtype = "int8" }, "kv-cache-dtype"),
+			Entry("valid max-num-seqs", func(vc *corev1alpha1.VLLMConfig) { vc.MaxNumSeqs = 64 }, ""),
+			Entry("negative max-num-seqs", func(vc *corev1alpha1.VLLMConfig) { vc.MaxNumSeqs = -1 }, "max-num-seqs"),
+			Entry("valid max-num-batched-tokens", func(vc *corev1alpha1.VLLMConfig) { vc.MaxNumBatchedTokens = 4096 }, ""),
+			Entry("max-num-batched-tokens below max-num-seqs", func(vc *corev1alpha1.VLLMConfig) {
+				vc.MaxNumSeqs = 256
+				vc.MaxNumBatchedTokens = 128
+			}, "max-num-batched-tokens"),
+			Entry("max-num-batched-tokens below max-model-len", func(vc *corev1alpha1.VLLMConfig) {
+				vc.MaxModelLen = 8192
+				vc.MaxNumBatchedTokens = 2048
+			}, "max-num-batched-tokens"),
+			Entry("max-num-batched-tokens below max-model-len with chunked prefill", func(vc *corev1alpha1.VLLMConfig) {
+				vc.MaxModelLen = 8192
+				vc.MaxNumBatchedTokens = 2048
+				vc.EnableChunkedPrefill = true
+			}, ""),
+			Entry("enable-prefix-caching", func(vc *corev1alpha1.VLLMConfig) { vc.EnablePrefixCaching = true }, ""),
+			Entry("valid swap-space", func(vc *corev1alpha1.VLLMConfig) { vc.SwapSpace = "0.5" }, ""),
+			Entry("swap-space that is not a number", func(vc *corev1alpha1.VLLMConfig) { vc.SwapSpace = "4GiB" }, "swap-space"),
+			Entry("negative swap-space", func(vc *corev1alpha1.VLLMConfig) { vc.SwapSpace = "-1" }, "swap-space"),
+			Entry("valid served-model-name", func(vc *corev1alpha1.VLLMConfig) { vc.ServedModelName = []string{"llama"} }, ""),
+			Entry("empty served-model-name", func(vc *corev1alpha1.VLLMConfig) { vc.ServedModelName = []string{" "} }, "served-model-name[0]"),
+			Entry("trust-remote-code", func(vc *corev1alpha1.VLLMConfig) { vc.TrustRemoteCode = true }, ""),
+			Entry("valid seed", func(vc *corev1alpha1.VLLMConfig) { vc.Seed = ptr.To(0) }, ""),
+			Entry("negative seed", func(vc *corev1alpha1.VLLMConfig) { vc.Seed = ptr.To(-1) }, "seed"),
+			Entry("revision", func(vc *corev1alpha1.VLLMConfig) { vc.Revision = "v1.0" }, ""),
+		)
+
+		It("Should deny extra arguments repeating flags owned by the operator", func() {
+			obj.Spec.VLLMConfig.ExtraArgs = []string{"--disable-log-requests", "--port=9000"}
+			obj.Spec.VLLMConfig.ExtraArgsMap = map[string]string{"max_model_len": "4096", "max-log-len": "100"}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.vLLMConfig.extraArgs[1]")))
+			Expect(err).To(MatchError(ContainSubstring("spec.vLLMConfig.extraArgsMap[max_model_len]")))
+			Expect(err).NotTo(MatchError(ContainSubstring("max-log-len")))
+		})
+
+		It("Should deny a flag set in both extraArgs and extraArgsMap", func() {
+			obj.Spec.VLLMConfig.ExtraArgs = []string{"--max-log-len", "100"}
+			obj.Spec.VLLMConfig.ExtraArgsMap = map[string]string{"--max-log-len": "200"}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(MatchError(ContainSubstring("spec.vLLMConfig.extraArgsMap[--max-log-len]")))
+		})
+
+		It("Should deny a tensor-parallel-size that does not divide the GPU count", func() {
+			obj.Spec.GPU = &corev1alpha1.GPUConfig{Count: 4}
+			obj.Spec.VLLMConfig.TensorParallelSize = 3
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(MatchError(ContainSubstring("spec.vLLMConfig.tensor-parallel-size")))
+
+			obj.Spec.VLLMConfig.TensorParallelSize = 2
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny a multi-node parallelism that does not use every GPU of the group", func() {
+			obj.Spec.GPU = &corev1alpha1.GPUConfig{Count: 8}
+			obj.Spec.MultiNode = &corev1alpha1.MultiNodeConfig{Size: 2}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+
+			obj.Spec.VLLMConfig.PipelineParallelSize = 4
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(MatchError(ContainSubstring("spec.vLLMConfig.pipeline-parallel-size")))
+
+			obj.Spec.VLLMConfig.TensorParallelSize = 4
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		DescribeTable("Should validate the autoscaling targets",
+			func(configure func(*corev1alpha1.AutoscalingConfig), errField string) {
+				obj.Spec.Autoscaling = &corev1alpha1.AutoscalingConfig{
+					MaxReplicas:        4,
+					NumRequestsWaiting: &corev1alpha1.MetricTarget{AverageValue: resource.MustParse("5")},
+				}
+				configure(obj.Spec.Autoscaling)
+				_, err := validator.ValidateCreate(ctx, obj)
+				if errField == "" {
+					Expect(err).NotTo(HaveOccurred())
+					return
+				}
+				Expect(err).To(MatchError(ContainSubstring("spec.autoscaling" + errField)))
+			},
+			Entry("valid queue target", func(as *corev1alpha1.AutoscalingConfig) {}, ""),
+			Entry("maxReplicas below minReplicas", func(as *corev1alpha1.AutoscalingConfig) { as.MinReplicas = ptr.To[int32](5) }, ".maxReplicas"),
+			Entry("zero minReplicas", func(as *corev1alpha1.AutoscalingConfig) { as.MinReplicas = ptr.To[int32](0) }, ".minReplicas"),
+			Entry("no target", func(as *corev1alpha1.AutoscalingConfig) { as.NumRequestsWaiting = nil }, ": Required value"),
+			Entry("zero target", func(as *corev1alpha1.AutoscalingConfig) {
+				as.NumRequestsWaiting.AverageValue = resource.MustParse("0")
+			}, ".numRequestsWaiting.averageValue"),
+			Entry("KV cache usage above 1", func(as *corev1alpha1.AutoscalingConfig) {
+				as.GPUCacheUsage = &corev1alpha1.MetricTarget{AverageValue: resource.MustParse("80")}
+			}, ".gpuCacheUsage.averageValue"),
+			Entry("valid KV cache usage", func(as *corev1alpha1.AutoscalingConfig) {
+				as.GPUCacheUsage = &corev1alpha1.MetricTarget{AverageValue: resource.MustParse("800m")}
+			}, ""),
+			Entry("valid operator provider", func(as *corev1alpha1.AutoscalingConfig) {
+				as.Provider = corev1alpha1.AutoscalingProviderOperator
+				as.Operator = &corev1alpha1.OperatorAutoscalerConfig{ScaleDownCooldown: &metav1.Duration{Duration: time.Minute}}
+			}, ""),
+			Entry("operator settings with the HPA", func(as *corev1alpha1.AutoscalingConfig) {
+				as.Operator = &corev1alpha1.OperatorAutoscalerConfig{}
+			}, ".operator"),
+			Entry("tokens per second with the operator provider", func(as *corev1alpha1.AutoscalingConfig) {
+				as.Provider = corev1alpha1.AutoscalingProviderOperator
+				as.TokensPerSecond = &corev1alpha1.MetricTarget{AverageValue: resource.MustParse("100")}
+			}, ".tokensPerSecond"),
+			Entry("zero sync period", func(as *corev1alpha1.AutoscalingConfig) {
+				as.Provider = corev1alpha1.AutoscalingProviderOperator
+				as.Operator = &corev1alpha1.OperatorAutoscalerConfig{SyncPeriod: &metav1.Duration{}}
+			}, ".operator.syncPeriod"),
+		)
+
+		It("Should deny multi-node groups run by a Deployment", func() {
+			obj.Spec.MultiNode = &corev1alpha1.MultiNodeConfig{Size: 2}
+			obj.Spec.WorkloadKind = corev1alpha1.WorkloadKindDeployment
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(MatchError(ContainSubstring("spec.workloadKind")))
+
+			obj.Spec.WorkloadKind = corev1alpha1.WorkloadKindStatefulSet
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny an idle time that is not positive", func() {
+			obj.Spec.Idle = &corev1alpha1.IdleConfig{}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(MatchError(ContainSubstring("spec.idle.after")))
+
+			obj.Spec.Idle.After = metav1.Duration{Duration: 30 * time.Minute}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny a liveness probe requiring several successes", func() {
+			obj.Spec.Probes = &corev1alpha1.ProbesConfig{
+				Readiness: &corev1alpha1.ProbeOverride{Probe: corev1.Probe{SuccessThreshold: 2}},
+				Liveness:  &corev1alpha1.ProbeOverride{Probe: corev1.Probe{SuccessThreshold: 2}},
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.probes.liveness.successThreshold")))
+			Expect(err).NotTo(MatchError(ContainSubstring("spec.probes.readiness")))
+		})
+
+		It("Should deny a port out of range", func() {
+			obj.Spec.VLLMConfig.Port = 70000
+			obj.Spec.Containers[0].Ports = nil
+			Expect(validator.ValidateUpdate(ctx, oldObj, obj)).Error().To(MatchError(ContainSubstring("spec.vLLMConfig.port")))
+		})
+
+		It("Should deny a container port colliding with the serving port", func() {
+			obj.Spec.Containers[0].Ports = []corev1.ContainerPort{
+				{Name: "http", ContainerPort: 8073},
+				{Name: "api", ContainerPort: 8072},
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.containers[0].ports[0].containerPort")))
+			Expect(err).To(MatchError(ContainSubstring("spec.containers[0].ports[1].name")))
+		})
+
+		It("Should admit other container ports next to the serving port", func() {
+			obj.Spec.Containers[0].Ports = []corev1.ContainerPort{
+				{Name: "http", ContainerPort: 8072},
+				{Name: "debug", ContainerPort: 5678},
+				{Name: "dns", ContainerPort: 53, Protocol: corev1.ProtocolUDP},
+			}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny args on the vllm container", func() {
+			obj.Spec.Containers[0].Args = []string{"--max-num-seqs", "64"}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.containers[0].args")))
+			Expect(err).To(MatchError(ContainSubstring("vLLMConfig.extraArgs")))
+
+			// only the vllm container gets generated arguments
+			obj.Spec.Containers[0].Args = nil
+			obj.Spec.Containers = append(obj.Spec.Containers, corev1.Container{Name: "auth-proxy", Image: "proxy:latest", Args: []string{"--upstream=http://localhost:8072"}})
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny an init container named like the model downloader", func() {
+			obj.Spec.Model.Cache = &corev1alpha1.ModelCacheConfig{Size: ptr.To(resource.MustParse("20Gi"))}
+			obj.Spec.InitContainers = []corev1.Container{{Name: "model-downloader", Image: "busybox:latest"}}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(MatchError(ContainSubstring("spec.initContainers[0].name")))
+		})
+
+		It("Should deny several containers when none is named vllm", func() {
+			obj.Spec.Containers[0].Name = "server"
+			obj.Spec.Containers = append(obj.Spec.Containers, corev1.Container{Name: "proxy", Image: "proxy:latest"})
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(MatchError(ContainSubstring(`"vllm"`)))
+		})
+
+		It("Should admit sidecars and native sidecar init containers", func() {
+			obj.Spec.Containers = append(obj.Spec.Containers, corev1.Container{Name: "auth-proxy", Image: "proxy:latest"})
+			obj.Spec.InitContainers = []corev1.Container{{
+				Name:          "log-shipper",
+				Image:         "fluent-bit:latest",
+				RestartPolicy: ptr.To(corev1.ContainerRestartPolicyAlways),
+			}}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny a model cache without size or existing claim", func() {
+			obj.Spec.Model.Cache = &corev1alpha1.ModelCacheConfig{}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(MatchError(ContainSubstring("spec.model.cache.size")))
+
+			obj.Spec.Model.Cache.ExistingClaim = "llama-weights"
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny a model source whose settings do not match its type", func() {
+			obj.Spec.Model.Source = &corev1alpha1.ModelSource{
+				Type: corev1alpha1.ModelSourceS3,
+				PVC:  &corev1alpha1.PVCModelSource{ClaimName: "weights"},
+			}
+			_, err := validator.ValidateCreate(ctx, obj)
+			Expect(err).To(MatchError(ContainSubstring("spec.model.source.s3")))
+			Expect(err).To(MatchError(ContainSubstring("spec.model.source.pvc")))
+
+			obj.Spec.Model.Source.PVC = nil
+			obj.Spec.Model.Source.S3 = &corev1alpha1.S3ModelSource{URI: "s3://models/llama"}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().NotTo(HaveOccurred())
+		})
+
+		It("Should deny a Hugging Face token with another model source", func() {
+			obj.Spec.Model.TokenSecretRef = &corev1alpha1.SecretKeyRef{Name: "hf-token"}
+			obj.Spec.Model.Source = &corev1alpha1.ModelSource{
+				Type:     corev1alpha1.ModelSourceHostPath,
+				HostPath: &corev1alpha1.HostPathModelSource{Path: "/mnt/models/llama"},
+			}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(MatchError(ContainSubstring("spec.model.tokenSecretRef")))
+		})
+
+		It("Should deny duplicate container names", func() {
+			obj.Spec.InitContainers = []corev1.Container{{Name: "vllm", Image: "busybox"}}
+			Expect(validator.ValidateCreate(ctx, obj)).Error().To(MatchError(ContainSubstring("spec.initContainers[0].name")))
+		})
+	})
+})